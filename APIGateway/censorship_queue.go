@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCensorshipWorkers     = 4
+	defaultCensorshipQueueSize   = 1000
+	defaultCensorshipMaxAttempts = 5
+	defaultCensorshipRetryDelay  = time.Second
+	maxDeadLetters               = 1000
+	// pendingPageSize - размер страницы при загрузке комментариев pending после перезапуска
+	pendingPageSize = 500
+)
+
+var ErrQueueFull = errors.New("censorship queue is full")
+
+// censorshipJob - комментарий, ожидающий проверки в CensorshipService
+type censorshipJob struct {
+	CommentID  int       `json:"comment_id"`
+	Text       string    `json:"text"`
+	Policy     string    `json:"policy,omitempty"`
+	RequestID  string    `json:"request_id"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error,omitempty"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+}
+
+// CensorshipQueue - очередь фоновой проверки комментариев.
+// Воркеры отправляют текст в CensorshipService и по вердикту одобряют или отклоняют
+// комментарий в CommentsService. Неудачные попытки повторяются с экспоненциальной задержкой,
+// после исчерпания попыток задача попадает в список dead letters.
+type CensorshipQueue struct {
+	jobs        chan censorshipJob
+	workers     int
+	maxAttempts int
+	retryDelay  time.Duration
+
+	ctx context.Context
+	wg  sync.WaitGroup
+
+	mu          sync.Mutex
+	deadLetters []censorshipJob
+}
+
+func NewCensorshipQueue(size, workers, maxAttempts int, retryDelay time.Duration) *CensorshipQueue {
+	return &CensorshipQueue{
+		jobs:        make(chan censorshipJob, size),
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		retryDelay:  retryDelay,
+	}
+}
+
+// Start запускает воркеры, они работают до отмены ctx
+func (q *CensorshipQueue) Start(ctx context.Context) {
+	q.ctx = ctx
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+}
+
+// Wait дожидается завершения воркеров после отмены контекста
+func (q *CensorshipQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Enqueue ставит комментарий в очередь без блокировки
+func (q *CensorshipQueue) Enqueue(job censorshipJob) error {
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// enqueueWait ставит задачу в очередь, дожидаясь свободного места, пока не отменен ctx
+func (q *CensorshipQueue) enqueueWait(ctx context.Context, job censorshipJob) error {
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+	select {
+	case q.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RecoverPending ставит в очередь комментарии, которые остались в статусе pending: очередь хранится
+// в памяти, и задачи, не обработанные до остановки, теряются. Заново проверяются и комментарии,
+// ожидающие ручной модерации: вердикт review оставляет их модератору. Возвращает число поставленных задач.
+func (q *CensorshipQueue) RecoverPending(ctx context.Context) (int, error) {
+	// Сначала загружаем все страницы: воркеры меняют статусы, и страницы по смещению сдвигались бы
+	var pending []Comment
+	for offset := 0; ; offset += pendingPageSize {
+		resp, err := commentsServiceClient.Get(fmt.Sprintf("/admin/comments?status=pending&limit=%d&offset=%d", pendingPageSize, offset), "")
+		if err != nil {
+			return 0, fmt.Errorf("failed to get pending comments: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := readResponseBody(resp)
+			return 0, fmt.Errorf("failed to get pending comments: status %d: %s", resp.StatusCode, string(body))
+		}
+		var page []Comment
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode pending comments: %w", err)
+		}
+		pending = append(pending, page...)
+		if len(page) < pendingPageSize {
+			break
+		}
+	}
+
+	enqueued := 0
+	for _, comment := range pending {
+		section, exists, err := newsSection(comment.NewsID, "")
+		if err != nil {
+			return enqueued, fmt.Errorf("failed to check news %d: %w", comment.NewsID, err)
+		}
+		if !exists {
+			continue
+		}
+		job := censorshipJob{CommentID: comment.ID, Text: comment.Text, Policy: policyForSection(section)}
+		if err := q.enqueueWait(ctx, job); err != nil {
+			return enqueued, err
+		}
+		enqueued++
+	}
+	return enqueued, nil
+}
+
+// Len возвращает число задач, ожидающих обработки
+func (q *CensorshipQueue) Len() int {
+	return len(q.jobs)
+}
+
+// DeadLetters возвращает копию списка задач, которые не удалось обработать
+func (q *CensorshipQueue) DeadLetters() []censorshipJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]censorshipJob{}, q.deadLetters...)
+}
+
+// RetryDeadLetters возвращает задачи из dead letters в очередь и возвращает их число
+func (q *CensorshipQueue) RetryDeadLetters() int {
+	q.mu.Lock()
+	jobs := q.deadLetters
+	q.deadLetters = nil
+	q.mu.Unlock()
+
+	retried := 0
+	for _, job := range jobs {
+		job.Attempts = 0
+		if err := q.Enqueue(job); err != nil {
+			q.addDeadLetter(job, err)
+			continue
+		}
+		retried++
+	}
+	return retried
+}
+
+func (q *CensorshipQueue) addDeadLetter(job censorshipJob, err error) {
+	job.LastError = err.Error()
+	slog.Error("Censorship job moved to dead letters",
+		"comment_id", job.CommentID,
+		"attempts", job.Attempts,
+		"error", err,
+		"request_id", job.RequestID,
+	)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.deadLetters = append(q.deadLetters, job)
+	if len(q.deadLetters) > maxDeadLetters {
+		q.deadLetters = q.deadLetters[len(q.deadLetters)-maxDeadLetters:]
+	}
+}
+
+func (q *CensorshipQueue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-q.jobs:
+			q.process(job)
+		}
+	}
+}
+
+func (q *CensorshipQueue) process(job censorshipJob) {
+	job.Attempts++
+	err := moderateComment(job)
+	if err == nil {
+		return
+	}
+
+	if job.Attempts >= q.maxAttempts {
+		q.addDeadLetter(job, err)
+		return
+	}
+
+	job.LastError = err.Error()
+	delay := q.retryDelay << (job.Attempts - 1)
+	slog.Warn("Censorship job failed, retrying",
+		"comment_id", job.CommentID,
+		"attempts", job.Attempts,
+		"delay", delay,
+		"error", err,
+		"request_id", job.RequestID,
+	)
+	time.AfterFunc(delay, func() {
+		if q.ctx.Err() != nil {
+			return
+		}
+		if err := q.Enqueue(job); err != nil {
+			q.addDeadLetter(job, err)
+		}
+	})
+}
+
+// moderateComment проверяет текст комментария и записывает решение в CommentsService
+func moderateComment(job censorshipJob) error {
+	verdict, err := validateText(job.Text, job.Policy, job.RequestID)
+	if err != nil {
+		return err
+	}
+
+	var action string
+	// automatic: решение применяется, только пока комментарий в статусе pending
+	body := map[string]interface{}{"automatic": true}
+	switch verdict.Verdict {
+	case "review":
+		// Комментарий остается в очереди ручной модерации. Если в тексте есть слова для маскирования,
+		// модератор видит и при одобрении публикует замаскированный текст, как при синхронной проверке.
+		if verdict.MaskedText == "" {
+			return nil
+		}
+		action = "mask"
+		body["text"] = verdict.MaskedText
+	case "mask":
+		// Публикуем текст с замаскированными словами
+		action = "approve"
+		body["text"] = verdict.MaskedText
+	case "reject":
+		action = "reject"
+		body["reason"] = verdict.Error
+		if verdict.Error == "" {
+			body["reason"] = "Rejected by censorship service"
+		}
+	default:
+		action = "approve"
+	}
+
+	resp, err := commentsServiceClient.Post(fmt.Sprintf("/admin/comments/%d/%s", job.CommentID, action), body, job.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to %s comment: %w", action, err)
+	}
+	if resp.StatusCode == http.StatusConflict {
+		// Модератор уже принял решение, оно важнее запоздавшей проверки
+		resp.Body.Close()
+		slog.Info("Comment is already moderated, skipping censorship verdict",
+			"comment_id", job.CommentID,
+			"verdict", verdict.Verdict,
+			"request_id", job.RequestID,
+		)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := readResponseBody(resp)
+		return fmt.Errorf("failed to %s comment: status %d: %s", action, resp.StatusCode, string(respBody))
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// validateText отправляет текст в CensorshipService и возвращает вердикт по политике policy.
+// Ответ 400 с телом ValidateResponse считается вердиктом reject, а не ошибкой.
+func validateText(text, policy, requestID string) (*ValidateResponse, error) {
+	resp, _, err := postValidate(text, policy, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate comment: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		body, _ := readResponseBody(resp)
+		return nil, fmt.Errorf("failed to validate comment: status %d: %s", resp.StatusCode, string(body))
+	}
+	defer resp.Body.Close()
+
+	var verdict ValidateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("failed to decode validation response: %w", err)
+	}
+	if resp.StatusCode == http.StatusBadRequest && verdict.Verdict == "" {
+		verdict.Verdict = "reject"
+	}
+
+	return &verdict, nil
+}
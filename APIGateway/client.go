@@ -10,8 +10,9 @@ import (
 )
 
 type HTTPClient struct {
-	client  *http.Client
-	baseURL string
+	client    *http.Client
+	baseURL   string
+	authToken string
 }
 
 func NewHTTPClient(baseURL string) *HTTPClient {
@@ -23,6 +24,12 @@ func NewHTTPClient(baseURL string) *HTTPClient {
 	}
 }
 
+// SetAuthToken задает токен, который передается в заголовке Authorization
+// для административных эндпоинтов сервиса
+func (c *HTTPClient) SetAuthToken(token string) {
+	c.authToken = token
+}
+
 func (c *HTTPClient) Get(path string, requestID string) (*http.Response, error) {
 	req, err := http.NewRequest("GET", c.baseURL+path, nil)
 	if err != nil {
@@ -30,6 +37,9 @@ func (c *HTTPClient) Get(path string, requestID string) (*http.Response, error)
 	}
 	req.Header.Set("X-Request-ID", requestID)
 	req.Header.Set("Content-Type", "application/json")
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -50,6 +60,9 @@ func (c *HTTPClient) Post(path string, body interface{}, requestID string) (*htt
 	}
 	req.Header.Set("X-Request-ID", requestID)
 	req.Header.Set("Content-Type", "application/json")
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -67,3 +80,16 @@ func readResponseBody(resp *http.Response) ([]byte, error) {
 	return body, nil
 }
 
+// proxyResponse передает клиенту ответ сервиса без изменений: статус, Content-Type и тело
+func proxyResponse(w http.ResponseWriter, resp *http.Response) {
+	body, err := readResponseBody(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+}
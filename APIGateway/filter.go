@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	maxPageSize = 100
+	// maxPage - наибольший номер страницы, как в NewsService
+	maxPage = 100000
+)
+
+var allowedFilterParams = map[string]bool{
+	"s":         true,
+	"page":      true,
+	"page_size": true,
+	"from":      true,
+	"to":        true,
+	"source":    true,
+	"section":   true,
+	"sort":      true,
+}
+
+var allowedSorts = map[string]bool{
+	"pub_time_desc": true,
+	"pub_time_asc":  true,
+	"relevance":     true,
+}
+
+// parseFilterQuery проверяет параметры /news/filter и возвращает их
+// в виде, пригодном для передачи в NewsService
+func parseFilterQuery(query url.Values) (url.Values, *APIError) {
+	for name := range query {
+		if !allowedFilterParams[name] {
+			return nil, invalidParam(name, "unknown parameter")
+		}
+	}
+	for name, values := range query {
+		if name != "source" && name != "section" && len(values) > 1 {
+			return nil, invalidParam(name, "parameter must be specified once")
+		}
+	}
+
+	params := url.Values{}
+	if s := query.Get("s"); s != "" {
+		params.Set("s", s)
+	}
+
+	if page := query.Get("page"); page != "" {
+		if n, err := strconv.Atoi(page); err != nil || n < 1 || n > maxPage {
+			return nil, invalidParam("page", fmt.Sprintf("must be an integer between 1 and %d", maxPage))
+		}
+		params.Set("page", page)
+	}
+
+	if pageSize := query.Get("page_size"); pageSize != "" {
+		if n, err := strconv.Atoi(pageSize); err != nil || n < 1 || n > maxPageSize {
+			return nil, invalidParam("page_size", fmt.Sprintf("must be an integer between 1 and %d", maxPageSize))
+		}
+		params.Set("page_size", pageSize)
+	}
+
+	var from, to time.Time
+	if v := query.Get("from"); v != "" {
+		t, ok := parseFilterTime(v, false)
+		if !ok {
+			return nil, invalidParam("from", "must be RFC3339 or YYYY-MM-DD")
+		}
+		from = t
+		params.Set("from", v)
+	}
+	if v := query.Get("to"); v != "" {
+		t, ok := parseFilterTime(v, true)
+		if !ok {
+			return nil, invalidParam("to", "must be RFC3339 or YYYY-MM-DD")
+		}
+		to = t
+		params.Set("to", v)
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return nil, invalidParam("from", "must not be after to")
+	}
+
+	for _, source := range query["source"] {
+		if source == "" {
+			return nil, invalidParam("source", "must not be empty")
+		}
+		params.Add("source", source)
+	}
+
+	for _, section := range query["section"] {
+		if section == "" {
+			return nil, invalidParam("section", "must not be empty")
+		}
+		params.Add("section", section)
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if !allowedSorts[sort] {
+			return nil, invalidParam("sort", "must be one of pub_time_desc, pub_time_asc, relevance")
+		}
+		if sort == "relevance" && query.Get("s") == "" {
+			return nil, invalidParam("sort", "relevance requires s")
+		}
+		params.Set("sort", sort)
+	}
+
+	return params, nil
+}
+
+// parseFilterTime разбирает время так же, как NewsService: дата без времени
+// в качестве верхней границы включает весь день
+func parseFilterTime(value string, endOfDay bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Microsecond)
+	}
+	return t, true
+}
+
+func invalidParam(name, message string) *APIError {
+	return &APIError{
+		Code:      "invalid_parameter",
+		Message:   message,
+		Parameter: name,
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseFilterQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		param string // пустой - запрос корректен
+	}{
+		{"from=2024-01-01&to=2024-01-01", ""},
+		{"from=2024-01-01T12:00:00Z&to=2024-01-01", ""},
+		{"from=2024-01-02&to=2024-01-01", "from"},
+		{"from=2024-01-01T12:00:00%2B03:00&to=2024-01-01T10:00:00Z", ""},
+		{"to=yesterday", "to"},
+		{"page=100000", ""},
+		{"page=100001", "page"},
+		{"page=9223372036854775807", "page"},
+		{"page=0", "page"},
+		{"sort=relevance", "sort"},
+		{"unknown=1", "unknown"},
+	}
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, apiErr := parseFilterQuery(query)
+		got := ""
+		if apiErr != nil {
+			got = apiErr.Parameter
+		}
+		if got != tt.param {
+			t.Errorf("%s: invalid parameter = %q, want %q", tt.query, got, tt.param)
+		}
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 )
@@ -22,6 +26,12 @@ var (
 	newsServiceClient       *HTTPClient
 	commentsServiceClient   *HTTPClient
 	censorshipServiceClient *HTTPClient
+	verifiedNews            *newsCache
+	censorshipQueue         *CensorshipQueue
+	asyncCensorship         bool
+	// censorshipMode - reject: комментарии с запрещенными словами отклоняются,
+	// mask: сохраняются с замаскированными словами
+	censorshipMode string
 )
 
 func main() {
@@ -29,16 +39,58 @@ func main() {
 	newsURL := flag.String("news-url", defaultNewsServiceURL, "News service URL")
 	commentsURL := flag.String("comments-url", defaultCommentsServiceURL, "Comments service URL")
 	censorshipURL := flag.String("censorship-url", defaultCensorshipServiceURL, "Censorship service URL")
+	newsCacheTTL := flag.Duration("news-cache-ttl", defaultNewsCacheTTL, "How long verified news existence is cached, 0 disables the cache")
+	flag.BoolVar(&asyncCensorship, "async-censorship", false, "Save comments as pending and validate them in background workers")
+	flag.StringVar(&censorshipMode, "censorship-mode", "reject", "What to do with forbidden words: reject the comment or mask them")
+	censorshipWorkers := flag.Int("censorship-workers", defaultCensorshipWorkers, "Number of background censorship workers")
+	censorshipQueueSize := flag.Int("censorship-queue-size", defaultCensorshipQueueSize, "Capacity of the censorship queue")
+	censorshipMaxAttempts := flag.Int("censorship-max-attempts", defaultCensorshipMaxAttempts, "Attempts before a censorship job is moved to dead letters")
+	commentsAdminToken := flag.String("comments-admin-token", "", "Bearer token for the CommentsService admin API")
+	adminToken := flag.String("admin-token", "", "Bearer token for the gateway admin API, empty disables the admin API")
+	policies := flag.String("section-policies", "", "Comma-separated section:policy pairs choosing the CensorshipService policy for news sections")
+	flag.StringVar(&defaultPolicy, "default-policy", "", "CensorshipService policy for sections without their own policy, empty means the service default")
 	flag.Parse()
 
+	if censorshipMode != "reject" && censorshipMode != "mask" {
+		log.Fatalf("-censorship-mode must be reject or mask")
+	}
+	var err error
+	sectionPolicies, err = parseSectionPolicies(*policies)
+	if err != nil {
+		log.Fatalf("Invalid -section-policies: %v", err)
+	}
+
 	newsServiceClient = NewHTTPClient(*newsURL)
 	commentsServiceClient = NewHTTPClient(*commentsURL)
 	censorshipServiceClient = NewHTTPClient(*censorshipURL)
+	commentsServiceClient.SetAuthToken(*commentsAdminToken)
+	verifiedNews = newNewsCache(*newsCacheTTL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	censorshipQueue = NewCensorshipQueue(*censorshipQueueSize, *censorshipWorkers, *censorshipMaxAttempts, defaultCensorshipRetryDelay)
+	censorshipQueue.Start(ctx)
+	if asyncCensorship {
+		go func() {
+			n, err := censorshipQueue.RecoverPending(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("Failed to recover pending comments", "error", err, "enqueued", n)
+				return
+			}
+			slog.Info("Pending comments enqueued for censorship", "count", n)
+		}()
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/news", handleNews)
 	mux.HandleFunc("/news/filter", handleFilterNews)
 	mux.HandleFunc("/news/", handleNewsByID)
+	mux.HandleFunc("/comments/", handleCommentStatus)
+	// Без токена административный API не регистрируется
+	if *adminToken != "" {
+		mux.Handle("/admin/censorship/", adminAuth(*adminToken, http.HandlerFunc(handleCensorshipQueue)))
+	}
 
 	handler := requestIDMiddleware(loggingMiddleware(mux))
 
@@ -62,6 +114,8 @@ func main() {
 	if err := server.Close(); err != nil {
 		log.Printf("Error closing server: %v", err)
 	}
+	cancel()
+	censorshipQueue.Wait()
 }
 
 func handleNews(w http.ResponseWriter, r *http.Request) {
@@ -73,16 +127,16 @@ func handleNews(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Header.Get("X-Request-ID")
 	query := r.URL.Query()
 
-	path := "/news"
+	params := url.Values{}
 	if s := query.Get("s"); s != "" {
-		path += "?s=" + s
+		params.Set("s", s)
 	}
 	if page := query.Get("page"); page != "" {
-		if path == "/news" {
-			path += "?page=" + page
-		} else {
-			path += "&page=" + page
-		}
+		params.Set("page", page)
+	}
+	path := "/news"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
 	}
 
 	resp, err := newsServiceClient.Get(path, requestID)
@@ -117,16 +171,14 @@ func handleFilterNews(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Header.Get("X-Request-ID")
 	query := r.URL.Query()
 
-	path := "/news"
-	if s := query.Get("s"); s != "" {
-		path += "?s=" + s
+	params, apiErr := parseFilterQuery(query)
+	if apiErr != nil {
+		writeJSONError(w, http.StatusBadRequest, *apiErr)
+		return
 	}
-	if page := query.Get("page"); page != "" {
-		if path == "/news" {
-			path += "?page=" + page
-		} else {
-			path += "&page=" + page
-		}
+	path := "/news"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
 	}
 
 	resp, err := newsServiceClient.Get(path, requestID)
@@ -182,6 +234,20 @@ func handleNewsByID(w http.ResponseWriter, r *http.Request) {
 
 	requestID := r.Header.Get("X-Request-ID")
 
+	// ?comments=tree запрашивает комментарии в виде дерева
+	commentsQuery := url.Values{}
+	commentsQuery.Set("news_id", strconv.Itoa(id))
+	treeFormat := r.URL.Query().Get("comments") == "tree"
+	if treeFormat {
+		commentsQuery.Set("format", "tree")
+		if depth := r.URL.Query().Get("comments_max_depth"); depth != "" {
+			commentsQuery.Set("max_depth", depth)
+		}
+		if sort := r.URL.Query().Get("comments_sort"); sort != "" {
+			commentsQuery.Set("sort", sort)
+		}
+	}
+
 	// Асинхронное получение данных из двух сервисов
 	type newsResult struct {
 		news *NewsFullDetailed
@@ -225,7 +291,7 @@ func handleNewsByID(w http.ResponseWriter, r *http.Request) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		resp, err := commentsServiceClient.Get(fmt.Sprintf("/comments?news_id=%d", id), requestID)
+		resp, err := commentsServiceClient.Get("/comments?"+commentsQuery.Encode(), requestID)
 		if err != nil {
 			commentsChan <- commentsResult{nil, err}
 			return
@@ -238,6 +304,16 @@ func handleNewsByID(w http.ResponseWriter, r *http.Request) {
 		}
 		defer resp.Body.Close()
 
+		if treeFormat {
+			var tree CommentTreeResponse
+			if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+				commentsChan <- commentsResult{nil, err}
+				return
+			}
+			commentsChan <- commentsResult{tree.Comments, nil}
+			return
+		}
+
 		var comments []Comment
 		if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
 			commentsChan <- commentsResult{nil, err}
@@ -276,25 +352,55 @@ func handleCreateComment(w http.ResponseWriter, r *http.Request, newsID int) {
 		return
 	}
 
-	// Сначала проверяем через сервис цензуры
-	validateReq := map[string]string{"text": req.Text}
-	resp, err := censorshipServiceClient.Post("/validate", validateReq, requestID)
+	if asyncCensorship {
+		handleCreateCommentAsync(w, r, newsID, req)
+		return
+	}
+
+	resp, policy, err := validateForNews(req.Text, newsID, requestID)
+	if errors.Is(err, errNewsNotFound) {
+		writeJSONError(w, http.StatusNotFound, APIError{
+			Code:    "news_not_found",
+			Message: fmt.Sprintf("news %d not found", newsID),
+		})
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to validate comment: %v", err), http.StatusInternalServerError)
 		return
 	}
-
 	if resp.StatusCode != http.StatusOK {
-		body, _ := readResponseBody(resp)
-		http.Error(w, string(body), resp.StatusCode)
+		proxyResponse(w, resp)
 		return
 	}
+
+	var validateResp ValidateResponse
+	err = json.NewDecoder(resp.Body).Decode(&validateResp)
 	resp.Body.Close()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode validation response: %v", err), http.StatusInternalServerError)
+		return
+	}
 
-	// Если валидация прошла, создаем комментарий
+	// Если валидация прошла, создаем комментарий.
+	// Комментарий, требующий проверки, сохраняется в очередь модерации.
+	// В режиме маскирования сохраняется текст с замаскированными словами, исходный текст
+	// CommentsService хранит для повторной модерации. Политика сохраняется с комментарием по той же причине.
+	status := "approved"
+	if validateResp.Verdict == "review" {
+		status = "pending"
+	}
 	createCommentReq := map[string]interface{}{
 		"news_id": newsID,
 		"text":    req.Text,
+		"status":  status,
+	}
+	if validateResp.MaskedText != "" {
+		createCommentReq["text"] = validateResp.MaskedText
+		createCommentReq["original_text"] = req.Text
+	}
+	if policy != "" {
+		createCommentReq["policy"] = policy
 	}
 	if req.ParentCommentID != nil {
 		createCommentReq["parent_comment_id"] = *req.ParentCommentID
@@ -307,8 +413,7 @@ func handleCreateComment(w http.ResponseWriter, r *http.Request, newsID int) {
 	}
 
 	if resp.StatusCode != http.StatusCreated {
-		body, _ := readResponseBody(resp)
-		http.Error(w, string(body), resp.StatusCode)
+		proxyResponse(w, resp)
 		return
 	}
 	defer resp.Body.Close()
@@ -7,24 +7,39 @@ type NewsShortDetailed struct {
 	Title   string    `json:"title"`
 	Content string    `json:"content"`
 	PubTime time.Time `json:"pub_time"`
+	Section string    `json:"section,omitempty"`
+	Snippet string    `json:"snippet,omitempty"`
 }
 
 type NewsFullDetailed struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	PubTime   time.Time `json:"pub_time"`
-	Link      string    `json:"link"`
-	Source    string    `json:"source"`
-	Comments  []Comment `json:"comments"`
+	ID       int       `json:"id"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	PubTime  time.Time `json:"pub_time"`
+	Link     string    `json:"link"`
+	Source   string    `json:"source"`
+	Section  string    `json:"section,omitempty"`
+	Comments []Comment `json:"comments"`
 }
 
 type Comment struct {
-	ID              int       `json:"id"`
-	NewsID          int       `json:"news_id"`
-	Text            string    `json:"text"`
-	ParentCommentID *int      `json:"parent_comment_id,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID               int       `json:"id"`
+	NewsID           int       `json:"news_id"`
+	Text             string    `json:"text"`
+	ParentCommentID  *int      `json:"parent_comment_id,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	Status           string    `json:"status,omitempty"`
+	ModerationReason string    `json:"moderation_reason,omitempty"`
+	Replies          []Comment `json:"replies,omitempty"`
+	Score            int       `json:"score,omitempty"`
+	MoreReplies      int       `json:"more_replies,omitempty"`
+	Orphan           bool      `json:"orphan,omitempty"`
+}
+
+type CommentTreeResponse struct {
+	Comments []Comment `json:"comments"`
+	Total    int       `json:"total"`
+	Orphans  int       `json:"orphans"`
 }
 
 type NewsListResponse struct {
@@ -39,3 +54,50 @@ type CreateCommentRequest struct {
 	ParentCommentID *int   `json:"parent_comment_id,omitempty"`
 }
 
+type ValidateResponse struct {
+	Valid       bool         `json:"valid"`
+	Verdict     string       `json:"verdict"`
+	Reason      string       `json:"reason,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Score       float64      `json:"score"`
+	Policy      string       `json:"policy,omitempty"`
+	MaskedText  string       `json:"masked_text,omitempty"`
+	MaskedSpans []MaskedSpan `json:"masked_spans,omitempty"`
+	Violations  []Violation  `json:"violations,omitempty"`
+}
+
+type Violation struct {
+	RuleID   string  `json:"rule_id"`
+	Source   string  `json:"source"`
+	Category string  `json:"category,omitempty"`
+	Severity string  `json:"severity"`
+	Weight   float64 `json:"weight"`
+	Action   string  `json:"action"`
+	Match    string  `json:"match"`
+	Start    int     `json:"start"`
+	End      int     `json:"end"`
+	Message  string  `json:"message,omitempty"`
+}
+
+type MaskedSpan struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	RuleID string `json:"rule_id"`
+}
+
+type CommentAcceptedResponse struct {
+	Comment
+	StatusURL string `json:"status_url"`
+}
+
+type CommentStatusResponse struct {
+	ID               int    `json:"id"`
+	NewsID           int    `json:"news_id"`
+	Status           string `json:"status"`
+	ModerationReason string `json:"moderation_reason,omitempty"`
+}
+
+type CensorshipQueueResponse struct {
+	Pending     int             `json:"pending"`
+	DeadLetters []censorshipJob `json:"dead_letters"`
+}
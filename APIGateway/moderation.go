@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// handleCreateCommentAsync сохраняет комментарий со статусом pending и ставит его
+// в очередь фоновой проверки. Клиент получает 202 и ссылку для опроса статуса.
+func handleCreateCommentAsync(w http.ResponseWriter, r *http.Request, newsID int, req CreateCommentRequest) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	section, exists, err := newsSection(newsID, requestID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to check news: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, APIError{
+			Code:    "news_not_found",
+			Message: fmt.Sprintf("news %d not found", newsID),
+		})
+		return
+	}
+
+	policy := policyForSection(section)
+	createCommentReq := map[string]interface{}{
+		"news_id": newsID,
+		"text":    req.Text,
+		"status":  "pending",
+	}
+	if policy != "" {
+		createCommentReq["policy"] = policy
+	}
+	if req.ParentCommentID != nil {
+		createCommentReq["parent_comment_id"] = *req.ParentCommentID
+	}
+
+	resp, err := commentsServiceClient.Post("/comments", createCommentReq, requestID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		proxyResponse(w, resp)
+		return
+	}
+	defer resp.Body.Close()
+
+	var comment Comment
+	if err := json.NewDecoder(resp.Body).Decode(&comment); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	job := censorshipJob{CommentID: comment.ID, Text: comment.Text, Policy: policy, RequestID: requestID}
+	if err := censorshipQueue.Enqueue(job); err != nil {
+		// Комментарий уже сохранен и останется в очереди ручной модерации
+		censorshipQueue.addDeadLetter(job, err)
+	}
+
+	statusURL := fmt.Sprintf("/comments/%d/status", comment.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", statusURL)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(CommentAcceptedResponse{
+		Comment:   comment,
+		StatusURL: statusURL,
+	})
+}
+
+// handleCommentStatus - GET /comments/{id}/status
+func handleCommentStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var id int
+	if _, err := fmt.Sscanf(r.URL.Path, "/comments/%d/status", &id); err != nil || !strings.HasSuffix(r.URL.Path, "/status") {
+		http.Error(w, "Invalid path", http.StatusNotFound)
+		return
+	}
+
+	resp, err := commentsServiceClient.Get(fmt.Sprintf("/comments/%d", id), r.Header.Get("X-Request-ID"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		proxyResponse(w, resp)
+		return
+	}
+	defer resp.Body.Close()
+
+	var comment Comment
+	if err := json.NewDecoder(resp.Body).Decode(&comment); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CommentStatusResponse{
+		ID:               comment.ID,
+		NewsID:           comment.NewsID,
+		Status:           comment.Status,
+		ModerationReason: comment.ModerationReason,
+	})
+}
+
+// handleCensorshipQueue - GET /admin/censorship/queue возвращает размер очереди и dead letters,
+// POST /admin/censorship/queue/retry возвращает dead letters в очередь
+func handleCensorshipQueue(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/censorship/queue":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CensorshipQueueResponse{
+			Pending:     censorshipQueue.Len(),
+			DeadLetters: censorshipQueue.DeadLetters(),
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/admin/censorship/queue/retry":
+		retried := censorshipQueue.RetryDeadLetters()
+		slog.Info("Dead letters returned to censorship queue", "count", retried)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"retried": retried})
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// adminAuth пропускает запрос только с заголовком Authorization: Bearer <token>.
+// Пустой токен не подходит ни к одному запросу.
+func adminAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, APIError{Code: "unauthorized", Message: "invalid admin token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultNewsCacheTTL = 5 * time.Minute
+
+// newsCache запоминает новости, существование которых уже подтверждено NewsService, вместе с их разделом.
+// Отсутствующие новости не кэшируются, чтобы только что добавленная новость сразу стала доступна.
+type newsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[int]newsCacheEntry
+}
+
+type newsCacheEntry struct {
+	section string
+	expires time.Time
+}
+
+func newNewsCache(ttl time.Duration) *newsCache {
+	return &newsCache{
+		ttl:     ttl,
+		entries: make(map[int]newsCacheEntry),
+	}
+}
+
+// Get возвращает раздел новости, если ее существование уже подтверждено
+func (c *newsCache) Get(id int) (string, bool) {
+	if c.ttl <= 0 {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, id)
+		return "", false
+	}
+	return entry.section, true
+}
+
+func (c *newsCache) Add(id int, section string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	// Перед добавлением чистим устаревшие записи, чтобы кэш не рос бесконечно
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[id] = newsCacheEntry{section: section, expires: now.Add(c.ttl)}
+}
+
+// newsSection проверяет наличие новости в NewsService с учетом кэша и возвращает ее раздел
+func newsSection(id int, requestID string) (section string, exists bool, err error) {
+	if section, ok := verifiedNews.Get(id); ok {
+		return section, true, nil
+	}
+
+	resp, err := newsServiceClient.Get(fmt.Sprintf("/news/%d", id), requestID)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var news NewsFullDetailed
+		if err := json.NewDecoder(resp.Body).Decode(&news); err != nil {
+			return "", false, fmt.Errorf("failed to decode news: %w", err)
+		}
+		verifiedNews.Add(id, news.Section)
+		return news.Section, true, nil
+	case http.StatusNotFound:
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var (
+	// sectionPolicies - политика CensorshipService для комментариев к новостям раздела
+	sectionPolicies = map[string]string{}
+	// defaultPolicy - политика для разделов без своей политики; пустая - политика по умолчанию CensorshipService
+	defaultPolicy string
+)
+
+// parseSectionPolicies разбирает список "раздел:политика,раздел:политика"
+func parseSectionPolicies(value string) (map[string]string, error) {
+	policies := map[string]string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		section, policy, ok := strings.Cut(item, ":")
+		section, policy = strings.TrimSpace(section), strings.TrimSpace(policy)
+		if !ok || section == "" || policy == "" {
+			return nil, fmt.Errorf("section policy must be in the form section:policy, got %q", item)
+		}
+		policies[strings.ToLower(section)] = policy
+	}
+	return policies, nil
+}
+
+// policyForSection возвращает политику раздела новости (без учета регистра),
+// для неизвестного раздела - политику по умолчанию
+func policyForSection(section string) string {
+	if policy, ok := sectionPolicies[strings.ToLower(strings.TrimSpace(section))]; ok {
+		return policy
+	}
+	return defaultPolicy
+}
+
+// validateRequest - тело запроса к /validate CensorshipService
+func validateRequest(text, policy string) map[string]string {
+	req := map[string]string{"text": text, "mode": censorshipMode}
+	if policy != "" {
+		req["policy"] = policy
+	}
+	return req
+}
+
+// postValidate отправляет текст в /validate CensorshipService и возвращает ответ и политику, по которой он проверен.
+// Если политики раздела в CensorshipService нет (ответ 400 с кодом unknown_policy), текст проверяется
+// по политике по умолчанию, а не отклоняется из-за настроек шлюза.
+func postValidate(text, policy, requestID string) (*http.Response, string, error) {
+	resp, err := censorshipServiceClient.Post("/validate", validateRequest(text, policy), requestID)
+	if err != nil || resp.StatusCode != http.StatusBadRequest || policy == defaultPolicy {
+		return resp, policy, err
+	}
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return nil, policy, err
+	}
+	// Вердикт reject тоже приходит с 400, но с полем verdict
+	var checkErr struct {
+		Code    string `json:"error"`
+		Verdict string `json:"verdict"`
+	}
+	if json.Unmarshal(body, &checkErr) != nil || checkErr.Verdict != "" || checkErr.Code != "unknown_policy" {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, policy, nil
+	}
+
+	slog.Warn("Unknown censorship policy, falling back to the default policy",
+		"policy", policy,
+		"default_policy", defaultPolicy,
+		"request_id", requestID,
+	)
+	resp, err = censorshipServiceClient.Post("/validate", validateRequest(text, defaultPolicy), requestID)
+	return resp, defaultPolicy, err
+}
+
+// errNewsNotFound - новости, к которой пишется комментарий, нет
+var errNewsNotFound = errors.New("news not found")
+
+// validateForNews проверяет текст комментария по политике раздела новости.
+// Раздел подтвержденной новости берется из кэша. Иначе проверка по политике по умолчанию идет
+// параллельно с запросом новости, чтобы не ждать NewsService. Если у раздела своя политика,
+// текст проверяется повторно: запрос в CensorshipService лишний, но только для новостей не из кэша.
+func validateForNews(text string, newsID int, requestID string) (*http.Response, string, error) {
+	if section, ok := verifiedNews.Get(newsID); ok {
+		return postValidate(text, policyForSection(section), requestID)
+	}
+
+	type validation struct {
+		resp   *http.Response
+		policy string
+		err    error
+	}
+	done := make(chan validation, 1)
+	go func() {
+		resp, policy, err := postValidate(text, defaultPolicy, requestID)
+		done <- validation{resp, policy, err}
+	}()
+
+	section, exists, err := newsSection(newsID, requestID)
+	first := <-done
+	if err != nil || !exists || policyForSection(section) != defaultPolicy {
+		if first.resp != nil {
+			first.resp.Body.Close()
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("check news: %w", err)
+		}
+		if !exists {
+			return nil, "", errNewsNotFound
+		}
+		return postValidate(text, policyForSection(section), requestID)
+	}
+	return first.resp, first.policy, first.err
+}
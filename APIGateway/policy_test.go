@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestPolicyForSectionIgnoresCase(t *testing.T) {
+	policies, err := parseSectionPolicies("Politics:strict, sport:lenient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectionPolicies, defaultPolicy = policies, "default"
+	defer func() { sectionPolicies, defaultPolicy = map[string]string{}, "" }()
+
+	tests := map[string]string{
+		"politics": "strict",
+		"POLITICS": "strict",
+		"Sport":    "lenient",
+		"culture":  "default",
+		"":         "default",
+	}
+	for section, want := range tests {
+		if got := policyForSection(section); got != want {
+			t.Errorf("policyForSection(%q) = %q, want %q", section, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Причины, по которым срабатывание правила не считается нарушением
+const (
+	SuppressedByURL       = "url"
+	SuppressedByAllowlist = "allowlist"
+	SuppressedByException = "exception"
+)
+
+// Allowlist - исключения, общие для всех правил словаря
+type Allowlist struct {
+	// Words - слова и фразы, внутри которых срабатывания правил не считаются нарушениями
+	Words []string `json:"words,omitempty" yaml:"words,omitempty"`
+	// IgnoreURLs - не считать нарушениями срабатывания внутри ссылок
+	IgnoreURLs bool `json:"ignore_urls,omitempty" yaml:"ignore_urls,omitempty"`
+}
+
+// exceptionTerm - нормализованное исключение. Rule - индекс правила, к которому оно относится, -1 - для всех правил
+type exceptionTerm struct {
+	Rule   int
+	Text   string
+	Script int
+	Term   normalizedText
+}
+
+// buildExceptions нормализует исключения из allowlist и правил и строит по ним автомат
+func (rs *RuleSet) buildExceptions() error {
+	rs.exceptionTerms = nil
+	add := func(rule int, owner, text string) error {
+		term, script := normalizeTerm(text)
+		if len(term.Runes) == 0 {
+			return fmt.Errorf("%s: exception %q has no letters or digits", owner, text)
+		}
+		rs.exceptionTerms = append(rs.exceptionTerms, exceptionTerm{Rule: rule, Text: strings.TrimSpace(text), Script: script, Term: term})
+		return nil
+	}
+
+	if rs.Allowlist != nil {
+		for _, word := range rs.Allowlist.Words {
+			if err := add(-1, "allowlist", word); err != nil {
+				return err
+			}
+		}
+	}
+	for i, rule := range rs.Rules {
+		for _, word := range rule.Exceptions {
+			if err := add(i, fmt.Sprintf("rule %q", rule.ID), word); err != nil {
+				return err
+			}
+		}
+	}
+
+	patterns := make([][]rune, len(rs.exceptionTerms))
+	scripts := make([]int, len(rs.exceptionTerms))
+	for i, e := range rs.exceptionTerms {
+		patterns[i], scripts[i] = e.Term.Runes, e.Script
+	}
+	rs.exceptions = newScriptMatcher(patterns, scripts)
+	return nil
+}
+
+// suppress отмечает нарушения словаря, которые попали в исключения: внутрь ссылки,
+// слова из allowlist или исключения самого правила. violations[i] соответствует matches[i].
+func (rs *RuleSet) suppress(original []rune, texts [scriptCount]normalizedText, matches []RuleMatch, violations []Violation) {
+	if len(matches) == 0 {
+		return
+	}
+
+	var hits []Hit
+	if len(rs.exceptionTerms) > 0 {
+		for _, hit := range rs.exceptions.FindAll(texts) {
+			e := rs.exceptionTerms[hit.Pattern]
+			if sameWords(texts[e.Script], e.Term, hit.Start) && coversCounts(texts[e.Script], e.Term, hit.Start) {
+				hits = append(hits, hit)
+			}
+		}
+	}
+	var links [][2]int
+	if rs.Allowlist != nil && rs.Allowlist.IgnoreURLs {
+		links = linkSpans(original)
+	}
+
+	for i, m := range matches {
+		v := &violations[i]
+		for _, link := range links {
+			if link[0] <= v.Start && v.End <= link[1] {
+				v.Suppressed, v.SuppressedBy = true, SuppressedByURL
+				break
+			}
+		}
+		if v.Suppressed {
+			continue
+		}
+		for _, hit := range hits {
+			e := rs.exceptionTerms[hit.Pattern]
+			if hit.Start > m.Start || m.End > hit.End || e.Rule >= 0 && e.Rule != m.Rule {
+				continue
+			}
+			v.Suppressed, v.SuppressedBy = true, SuppressedByAllowlist+":"+e.Text
+			if e.Rule >= 0 {
+				v.SuppressedBy = SuppressedByException + ":" + e.Text
+			}
+			break
+		}
+	}
+}
+
+// sameWords проверяет, что исключение, найденное с позиции start, начинается с начала слова
+// и разбито на слова так же, как в тексте. Конец исключения может быть внутри слова: так оно покрывает словоформы.
+func sameWords(text normalizedText, term normalizedText, start int) bool {
+	if !text.Boundary[start] {
+		return false
+	}
+	for i := 1; i < len(term.Runes); i++ {
+		if text.Boundary[start+i] != term.Boundary[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// linkSpans возвращает ссылки в тексте как фрагменты [start, end) в рунах
+func linkSpans(text []rune) [][2]int {
+	s := string(text)
+	var spans [][2]int
+	for _, link := range linkPattern.FindAllStringIndex(s, -1) {
+		start := utf8.RuneCountInString(s[:link[0]])
+		spans = append(spans, [2]int{start, start + utf8.RuneCountInString(s[link[0]:link[1]])})
+	}
+	return spans
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const maxAuditEntries = 1000
+
+// AuditEntry - одно изменение словаря через административный API
+type AuditEntry struct {
+	Time            time.Time `json:"time"`
+	Actor           string    `json:"actor"`
+	Action          string    `json:"action"`
+	RuleID          string    `json:"rule_id"`
+	Before          *Rule     `json:"before,omitempty"`
+	After           *Rule     `json:"after,omitempty"`
+	PreviousVersion string    `json:"previous_version"`
+	Version         string    `json:"version"`
+	RequestID       string    `json:"request_id,omitempty"`
+}
+
+// AuditLog дописывает изменения в файл JSONL и хранит последние записи в памяти.
+// Без файла журнал живет только до перезапуска.
+type AuditLog struct {
+	path string
+
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+// NewAuditLog открывает журнал и загружает из файла последние записи
+func NewAuditLog(path string) (*AuditLog, error) {
+	a := &AuditLog{path: path}
+	if path == "" {
+		return a, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse audit log: %w", err)
+		}
+		a.append(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return a, nil
+}
+
+func (a *AuditLog) append(entry AuditEntry) {
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > maxAuditEntries {
+		a.entries = a.entries[len(a.entries)-maxAuditEntries:]
+	}
+}
+
+// Record сохраняет запись в журнал
+func (a *AuditLog) Record(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.append(entry)
+	if a.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Recent возвращает последние limit записей, новые - первыми. ruleID фильтрует записи по правилу.
+func (a *AuditLog) Recent(limit int, ruleID string) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	result := []AuditEntry{}
+	for i := len(a.entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if ruleID != "" && a.entries[i].RuleID != ruleID {
+			continue
+		}
+		result = append(result, a.entries[i])
+	}
+	return result
+}
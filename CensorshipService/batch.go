@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultMaxBatchSize = 1000
+	defaultMaxBodySize  = 16 * 1024 * 1024
+	maxStreamLineSize   = 1024 * 1024
+)
+
+var (
+	validationPool *WorkerPool
+	maxBatchSize   = defaultMaxBatchSize
+	// maxBodySize ограничивает тело /validate/batch, /rules/dry-run и /classifier/retrain: число элементов проверяется
+	// только после разбора, поэтому без ограничения большое тело целиком читается в память
+	maxBodySize int64 = defaultMaxBodySize
+)
+
+// WorkerPool - фиксированный набор горутин для проверки текстов. Общий для всех пакетных запросов,
+// поэтому число одновременных проверок ограничено независимо от числа запросов.
+type WorkerPool struct {
+	tasks   chan func()
+	workers int
+}
+
+func NewWorkerPool(workers int) *WorkerPool {
+	p := &WorkerPool{tasks: make(chan func()), workers: workers}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// Submit передает задачу свободному воркеру, блокируясь, пока все заняты
+func (p *WorkerPool) Submit(task func()) {
+	p.tasks <- task
+}
+
+// BatchItem - один текст пакетного запроса. ID возвращается в ответе без изменений.
+type BatchItem struct {
+	ID     json.RawMessage `json:"id"`
+	Text   string          `json:"text"`
+	Mode   string          `json:"mode,omitempty"`
+	Policy string          `json:"policy,omitempty"`
+}
+
+// BatchRequest - тело POST /validate/batch. Mode и Policy применяются к элементам, где они не заданы.
+type BatchRequest struct {
+	Items  []BatchItem `json:"items"`
+	Mode   string      `json:"mode,omitempty"`
+	Policy string      `json:"policy,omitempty"`
+}
+
+// BatchResult - вердикт для одного элемента. Failure заполняется, если элемент не удалось проверить,
+// FailureCode - код ошибки запроса проверки (unknown_policy, invalid_mode).
+type BatchResult struct {
+	ID   json.RawMessage `json:"id"`
+	Line int             `json:"line,omitempty"`
+	*ValidateResponse
+	Failure     string `json:"failure,omitempty"`
+	FailureCode string `json:"failure_code,omitempty"`
+}
+
+type BatchResponse struct {
+	RulesVersion string        `json:"rules_version"`
+	Results      []BatchResult `json:"results"`
+}
+
+func checkItem(rules *RuleSet, item BatchItem) BatchResult {
+	result := BatchResult{ID: item.ID}
+	if len(result.ID) == 0 {
+		result.ID = json.RawMessage("null")
+	}
+	resp, err := checkText(rules, ValidateRequest{Text: item.Text, Mode: item.Mode, Policy: item.Policy})
+	if err != nil {
+		result.Failure = err.Error()
+		result.FailureCode = checkErrorCode(err)
+		return result
+	}
+	result.ValidateResponse = &resp
+	return result
+}
+
+// handleValidateBatch - POST /validate/batch. Принимает {"items": [{"id": ..., "text": ...}], "mode": ..., "policy": ...}
+// или просто массив элементов. Все элементы проверяются по одной версии словаря.
+func handleValidateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BatchRequest
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBodySize))
+	first, err := peekNonSpace(body)
+	if err != nil {
+		invalidBody(w, err)
+		return
+	}
+	if first == '[' {
+		err = json.NewDecoder(body).Decode(&req.Items)
+	} else {
+		err = json.NewDecoder(body).Decode(&req)
+	}
+	if err != nil {
+		invalidBody(w, err)
+		return
+	}
+	if len(req.Items) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch is too large: %d items, at most %d allowed", len(req.Items), maxBatchSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	rules := dictionary.Current()
+	if _, _, err := checkOptions(rules, req.Mode, req.Policy); err != nil {
+		writeCheckError(w, err)
+		return
+	}
+
+	results := checkItems(rules, req.Items, req.Mode, req.Policy)
+	for _, result := range results {
+		if result.ValidateResponse != nil && !skipStats(r) {
+			hitStats.Record(*result.ValidateResponse)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BatchResponse{RulesVersion: rules.Version, Results: results})
+}
+
+// invalidBody отвечает на ошибку чтения тела запроса: 413, если тело больше maxBodySize, иначе 400
+func invalidBody(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Request body is too large: at most %d bytes allowed", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+}
+
+// checkItems проверяет элементы в общем пуле воркеров и возвращает результаты в порядке элементов.
+// mode и policy применяются к элементам, где они не заданы.
+func checkItems(rules *RuleSet, items []BatchItem, mode, policy string) []BatchResult {
+	results := make([]BatchResult, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		if item.Mode == "" {
+			item.Mode = mode
+		}
+		if item.Policy == "" {
+			item.Policy = policy
+		}
+		i, item := i, item
+		wg.Add(1)
+		validationPool.Submit(func() {
+			defer wg.Done()
+			results[i] = checkItem(rules, item)
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+// handleValidateStream - POST /validate/stream?mode=...&policy=...
+// Вход и выход - NDJSON: по одному элементу {"id": ..., "text": ...} в строке, результаты идут в порядке входа
+// по мере готовности. Число элементов в обработке ограничено, поэтому размер входа не ограничен.
+func handleValidateStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Все строки проверяются по одной версии словаря
+	rules := dictionary.Current()
+	mode, policy := r.URL.Query().Get("mode"), r.URL.Query().Get("policy")
+	record := !skipStats(r)
+	if _, _, err := checkOptions(rules, mode, policy); err != nil {
+		writeCheckError(w, err)
+		return
+	}
+
+	// Ответ пишется, пока читается тело запроса
+	rc := http.NewResponseController(w)
+	rc.EnableFullDuplex()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Rules-Version", rules.Version)
+	w.WriteHeader(http.StatusOK)
+
+	// pending хранит результаты в порядке входа; емкость ограничивает число элементов в обработке
+	pending := make(chan chan BatchResult, 2*validationPool.workers)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		encoder := json.NewEncoder(w)
+		for result := range pending {
+			encoder.Encode(<-result)
+			if len(pending) == 0 {
+				rc.Flush()
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		result := make(chan BatchResult, 1)
+		pending <- result
+
+		var item BatchItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			result <- BatchResult{ID: json.RawMessage("null"), Line: line, Failure: fmt.Sprintf("invalid json: %v", err)}
+			continue
+		}
+		if item.Mode == "" {
+			item.Mode = mode
+		}
+		if item.Policy == "" {
+			item.Policy = policy
+		}
+
+		n := line
+		validationPool.Submit(func() {
+			res := checkItem(rules, item)
+			res.Line = n
+			if res.ValidateResponse != nil && record {
+				hitStats.Record(*res.ValidateResponse)
+			}
+			result <- res
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		result := make(chan BatchResult, 1)
+		result <- BatchResult{ID: json.RawMessage("null"), Line: line + 1, Failure: fmt.Sprintf("failed to read input: %v", err)}
+		pending <- result
+	}
+
+	close(pending)
+	<-done
+}
+
+// peekNonSpace возвращает первый непробельный байт, не извлекая его из reader
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, errors.New("empty body")
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
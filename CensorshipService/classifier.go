@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Метки обучающих примеров: clean - одобренный комментарий, abusive - отклоненный
+const (
+	LabelClean   = "clean"
+	LabelAbusive = "abusive"
+
+	SourceClassifier = "classifier"
+)
+
+const (
+	defaultClassifierThreshold = 0.9
+	defaultClassifierWeight    = 3
+	// minClassifierExamples - сколько примеров каждого класса нужно, чтобы классификатор учитывался
+	minClassifierExamples = 20
+)
+
+var ErrInvalidLabel = errors.New("label must be clean or abusive")
+
+// Example - размеченный пример, строка JSONL-корпуса
+type Example struct {
+	Text  string `json:"text"`
+	Label string `json:"label"`
+}
+
+// nbModel - мультиномиальный наивный байесовский классификатор на словах нормализованного текста
+type nbModel struct {
+	Docs   map[string]int            `json:"docs"`
+	Tokens map[string]int            `json:"tokens"`
+	Counts map[string]map[string]int `json:"counts"`
+	Vocab  map[string]int            `json:"vocab"`
+}
+
+func newNBModel() *nbModel {
+	m := &nbModel{
+		Docs:   map[string]int{},
+		Tokens: map[string]int{},
+		Counts: map[string]map[string]int{},
+		Vocab:  map[string]int{},
+	}
+	for _, label := range []string{LabelClean, LabelAbusive} {
+		m.Counts[label] = map[string]int{}
+	}
+	return m
+}
+
+// tokenize разбивает текст на слова после той же нормализации, что и для словаря
+func tokenize(text string) []string {
+	// Слова приводятся к одному алфавиту, чтобы замена похожих букв не давала новых слов
+	nt := normalize(text)[scriptLatin]
+	var tokens []string
+	start := 0
+	for i := 1; i <= len(nt.Runes); i++ {
+		if nt.Boundary[i] {
+			tokens = append(tokens, string(nt.Runes[start:i]))
+			start = i
+		}
+	}
+	return tokens
+}
+
+func (m *nbModel) add(example Example) {
+	m.Docs[example.Label]++
+	for _, token := range tokenize(example.Text) {
+		m.Counts[example.Label][token]++
+		m.Tokens[example.Label]++
+		m.Vocab[token]++
+	}
+}
+
+// ready сообщает, достаточно ли примеров каждого класса
+func (m *nbModel) ready() bool {
+	return m.Docs[LabelClean] >= minClassifierExamples && m.Docs[LabelAbusive] >= minClassifierExamples
+}
+
+// probability возвращает вероятность класса abusive со сглаживанием Лапласа
+func (m *nbModel) probability(text string) float64 {
+	total := float64(m.Docs[LabelClean] + m.Docs[LabelAbusive])
+	vocab := float64(len(m.Vocab))
+	// Неизвестные модели слова не влияют на оценку
+	var tokens []string
+	for _, token := range tokenize(text) {
+		if _, known := m.Vocab[token]; known {
+			tokens = append(tokens, token)
+		}
+	}
+	scores := map[string]float64{}
+	for _, label := range []string{LabelClean, LabelAbusive} {
+		score := math.Log(float64(m.Docs[label]) / total)
+		denominator := float64(m.Tokens[label]) + vocab
+		for _, token := range tokens {
+			score += math.Log((float64(m.Counts[label][token]) + 1) / denominator)
+		}
+		scores[label] = score
+	}
+	// softmax по двум классам
+	return 1 / (1 + math.Exp(scores[LabelClean]-scores[LabelAbusive]))
+}
+
+// Classifier - обучаемый классификатор комментариев. Модель хранится в JSON-файле,
+// дообучается по обратной связи и переобучается целиком из корпуса.
+type Classifier struct {
+	path      string
+	threshold float64
+	weight    float64
+	// feedbackPath - JSONL-файл, куда дописываются примеры обратной связи, чтобы учесть их при переобучении
+	feedbackPath string
+
+	mu    sync.RWMutex
+	model *nbModel
+	// version растет при каждом изменении модели. Файл пишется вне mu под saveMu,
+	// и снимок старее уже записанного (savedVersion) не перезаписывает более новый.
+	version      uint64
+	saveMu       sync.Mutex
+	savedVersion uint64
+}
+
+var classifier *Classifier
+
+// NewClassifier загружает модель из файла path, если он существует. Пустой path - модель только в памяти.
+func NewClassifier(path, feedbackPath string, threshold, weight float64) (*Classifier, error) {
+	c := &Classifier{path: path, feedbackPath: feedbackPath, threshold: threshold, weight: weight, model: newNBModel()}
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read classifier model: %w", err)
+	}
+	model := newNBModel()
+	if err := json.Unmarshal(data, model); err != nil {
+		return nil, fmt.Errorf("failed to parse classifier model: %w", err)
+	}
+	c.model = model
+	return c, nil
+}
+
+// Check добавляет нарушение, если вероятность abusive не меньше порога.
+// Возвращает вероятность или nil, если модель еще не обучена.
+func (c *Classifier) Check(text []rune) (*float64, []Violation) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.model.ready() {
+		return nil, nil
+	}
+	p := c.model.probability(string(text))
+	if p < c.threshold {
+		return &p, nil
+	}
+
+	start, end := trimmedSpan(text)
+	return &p, []Violation{{
+		RuleID:   "classifier",
+		Source:   SourceClassifier,
+		Category: LabelAbusive,
+		Severity: SeverityMedium,
+		Weight:   c.weight,
+		Action:   ActionReview,
+		Match:    string(text[start:end]),
+		Start:    start,
+		End:      end,
+		Message:  fmt.Sprintf("Classifier considers comment abusive with probability %.2f", p),
+	}}
+}
+
+// Feedback дообучает модель на одном примере, сохраняет ее и дописывает пример в файл обратной связи.
+// Под блокировкой модель только меняется и копируется, запись на диск не задерживает проверки.
+func (c *Classifier) Feedback(example Example) error {
+	if example.Label != LabelClean && example.Label != LabelAbusive {
+		return ErrInvalidLabel
+	}
+	c.mu.Lock()
+	c.model.add(example)
+	c.version++
+	version := c.version
+	data, err := c.snapshotLocked(c.model)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := c.save(version, data); err != nil {
+		return err
+	}
+	if c.feedbackPath == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(example)
+	if err != nil {
+		return fmt.Errorf("failed to encode feedback: %w", err)
+	}
+	f, err := os.OpenFile(c.feedbackPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open feedback file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write feedback: %w", err)
+	}
+	return nil
+}
+
+// Retrain обучает новую модель на JSONL-корпусе и заменяет ею текущую
+func (c *Classifier) Retrain(corpus io.Reader) (ClassifierStats, error) {
+	model, err := trainModel(corpus)
+	if err != nil {
+		return ClassifierStats{}, err
+	}
+	data, err := c.snapshotLocked(model)
+	if err != nil {
+		return ClassifierStats{}, err
+	}
+
+	c.mu.Lock()
+	c.model = model
+	c.version++
+	version := c.version
+	stats := c.statsLocked()
+	c.mu.Unlock()
+
+	if err := c.save(version, data); err != nil {
+		return ClassifierStats{}, err
+	}
+	return stats, nil
+}
+
+// ClassifierStats - состояние модели
+type ClassifierStats struct {
+	Examples   map[string]int `json:"examples"`
+	Vocabulary int            `json:"vocabulary"`
+	Ready      bool           `json:"ready"`
+	Threshold  float64        `json:"threshold"`
+}
+
+func (c *Classifier) Stats() ClassifierStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.statsLocked()
+}
+
+func (c *Classifier) statsLocked() ClassifierStats {
+	return ClassifierStats{
+		Examples:   map[string]int{LabelClean: c.model.Docs[LabelClean], LabelAbusive: c.model.Docs[LabelAbusive]},
+		Vocabulary: len(c.model.Vocab),
+		Ready:      c.model.ready(),
+		Threshold:  c.threshold,
+	}
+}
+
+// trainModel читает корпус: по одному Example в строке
+func trainModel(corpus io.Reader) (*nbModel, error) {
+	model := newNBModel()
+	scanner := bufio.NewScanner(corpus)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var example Example
+		if err := json.Unmarshal(scanner.Bytes(), &example); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if example.Label != LabelClean && example.Label != LabelAbusive {
+			return nil, fmt.Errorf("line %d: %w", line, ErrInvalidLabel)
+		}
+		model.add(example)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+	return model, nil
+}
+
+// snapshotLocked кодирует модель для записи в файл. Вызывается под c.mu, если модель уже используется.
+func (c *Classifier) snapshotLocked(model *nbModel) ([]byte, error) {
+	if c.path == "" {
+		return nil, nil
+	}
+	data, err := json.Marshal(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode classifier model: %w", err)
+	}
+	return data, nil
+}
+
+// save атомарно записывает снимок модели версии version в файл, если не записан более новый
+func (c *Classifier) save(version uint64, data []byte) error {
+	if c.path == "" {
+		return nil
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	if version <= c.savedVersion {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".model-*")
+	if err != nil {
+		return fmt.Errorf("failed to save classifier model: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save classifier model: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save classifier model: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save classifier model: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to save classifier model: %w", err)
+	}
+	c.savedVersion = version
+	return nil
+}
+
+// handleClassifier - GET /classifier: состояние модели
+func handleClassifier(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(classifier.Stats())
+}
+
+// handleClassifierFeedback - POST /classifier/feedback {"text": "...", "label": "clean|abusive"}
+func handleClassifierFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var example Example
+	if err := json.NewDecoder(r.Body).Decode(&example); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	err := classifier.Feedback(example)
+	if errors.Is(err, ErrInvalidLabel) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save feedback: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(classifier.Stats())
+}
+
+// handleClassifierRetrain - POST /classifier/retrain, тело - JSONL-корпус с примерами
+func handleClassifierRetrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := classifier.Retrain(http.MaxBytesReader(w, r.Body, maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Corpus is too large: at most %d bytes allowed", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrain classifier: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// runTrainCommand - подкоманда "train": обучает модель на JSONL-корпусе и сохраняет ее в файл
+func runTrainCommand(args []string) error {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	corpusPath := fs.String("corpus", "", "Path to the JSONL corpus with {\"text\", \"label\"} lines, - for stdin")
+	modelPath := fs.String("model", "", "Path where the trained model is written")
+	fs.Parse(args)
+
+	if *corpusPath == "" || *modelPath == "" {
+		return fmt.Errorf("-corpus and -model are required")
+	}
+
+	corpus := io.Reader(os.Stdin)
+	if *corpusPath != "-" {
+		f, err := os.Open(*corpusPath)
+		if err != nil {
+			return fmt.Errorf("failed to open corpus: %w", err)
+		}
+		defer f.Close()
+		corpus = f
+	}
+
+	c := &Classifier{path: *modelPath, model: newNBModel()}
+	stats, err := c.Retrain(corpus)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Trained on %d clean and %d abusive examples, vocabulary %d words\n",
+		stats.Examples[LabelClean], stats.Examples[LabelAbusive], stats.Vocabulary)
+	if !stats.Ready {
+		fmt.Printf("Warning: the model needs at least %d examples of each label to be used\n", minClassifierExamples)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCorpus - JSONL-корпус из n чистых и n оскорбительных примеров
+func testCorpus(n int) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	for i := 0; i < n; i++ {
+		encoder.Encode(Example{Text: fmt.Sprintf("спасибо за статью номер %d", i), Label: LabelClean})
+		encoder.Encode(Example{Text: fmt.Sprintf("ты qwerty и zxvbnm %d", i), Label: LabelAbusive})
+	}
+	return b.String()
+}
+
+func TestClassifierTrainAndPredict(t *testing.T) {
+	c, err := NewClassifier("", "", defaultClassifierThreshold, defaultClassifierWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := c.Retrain(strings.NewReader(testCorpus(minClassifierExamples - 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready {
+		t.Fatalf("model with %d examples of each label is ready", minClassifierExamples-1)
+	}
+	if p, violations := c.Check([]rune("ты qwerty")); p != nil || violations != nil {
+		t.Errorf("model that is not ready: p = %v, violations = %v", p, violations)
+	}
+
+	if _, err := c.Retrain(strings.NewReader(testCorpus(minClassifierExamples))); err != nil {
+		t.Fatal(err)
+	}
+	p, violations := c.Check([]rune("ты qwerty"))
+	if p == nil || *p < defaultClassifierThreshold || len(violations) != 1 {
+		t.Fatalf("abusive text: p = %v, violations = %v", p, violations)
+	}
+	if v := violations[0]; v.Source != SourceClassifier || v.Action != ActionReview || v.Match != "ты qwerty" {
+		t.Errorf("violation = %+v", v)
+	}
+	p, violations = c.Check([]rune("спасибо за статью"))
+	if p == nil || *p >= defaultClassifierThreshold || violations != nil {
+		t.Errorf("clean text: p = %v, violations = %v", p, violations)
+	}
+}
+
+func TestClassifierRetrainInvalidCorpus(t *testing.T) {
+	c, err := NewClassifier("", "", defaultClassifierThreshold, defaultClassifierWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpus := testCorpus(1) + `{"text": "qwerty", "label": "spam"}` + "\n"
+	if _, err := c.Retrain(strings.NewReader(corpus)); !errors.Is(err, ErrInvalidLabel) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("err = %v, want ErrInvalidLabel on line 3", err)
+	}
+}
+
+func TestClassifierPersistence(t *testing.T) {
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "model.json")
+	feedbackPath := filepath.Join(dir, "feedback.jsonl")
+
+	c, err := NewClassifier(modelPath, feedbackPath, defaultClassifierThreshold, defaultClassifierWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Retrain(strings.NewReader(testCorpus(minClassifierExamples))); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Feedback(Example{Text: "qwerty", Label: "spam"}); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("feedback with invalid label: err = %v", err)
+	}
+	feedback := []Example{{Text: "отличная новость", Label: LabelClean}, {Text: "zxvbnm", Label: LabelAbusive}}
+	for _, example := range feedback {
+		if err := c.Feedback(example); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := c.Stats()
+	if want.Examples[LabelClean] != minClassifierExamples+1 || want.Examples[LabelAbusive] != minClassifierExamples+1 {
+		t.Errorf("examples after feedback = %v", want.Examples)
+	}
+	loaded, err := NewClassifier(modelPath, "", defaultClassifierThreshold, defaultClassifierWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Stats(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("loaded stats = %v, want %v", got, want)
+	}
+
+	data, err := os.ReadFile(feedbackPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []Example
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var example Example
+		if err := decoder.Decode(&example); err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, example)
+	}
+	if fmt.Sprint(saved) != fmt.Sprint(feedback) {
+		t.Errorf("feedback file = %v, want %v", saved, feedback)
+	}
+}
+
+func TestClassifierKeepsNewerSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	c := &Classifier{path: path, model: newNBModel()}
+	if err := c.save(2, []byte(`{"version": 2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.save(1, []byte(`{"version": 1}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"version": 2}` {
+		t.Errorf("model file = %s, want the newer snapshot", data)
+	}
+}
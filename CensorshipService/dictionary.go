@@ -0,0 +1,809 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultDictionaryPollInterval = 5 * time.Second
+
+var (
+	ErrVersionMismatch = errors.New("dictionary version mismatch")
+	ErrReadOnly        = errors.New("dictionary file format is read-only")
+	ErrNoFile          = errors.New("dictionary is not backed by a file, changes would be lost on restart")
+	ErrRuleNotFound    = errors.New("rule not found")
+	ErrRuleExists      = errors.New("rule already exists")
+	ErrInvalidRuleSet  = errors.New("invalid dictionary")
+)
+
+const (
+	ActionReject = "reject"
+	ActionReview = "review"
+)
+
+// Уровни серьезности нарушения
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// Режимы сравнения термина с текстом
+const (
+	// MatchSubstring - термин может быть частью слова
+	MatchSubstring = "substring"
+	// MatchWord - термин должен совпасть со словом (или несколькими словами) целиком
+	MatchWord = "word"
+	// MatchStem - слово текста должно иметь ту же основу, что и термин: покрывает словоформы
+	MatchStem = "stem"
+)
+
+// Rule - одно правило словаря
+type Rule struct {
+	ID       string `json:"id" yaml:"id"`
+	Term     string `json:"term" yaml:"term"`
+	Action   string `json:"action,omitempty" yaml:"action,omitempty"`
+	Mode     string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Severity string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	// Weight - вклад правила в суммарный вес нарушений, по умолчанию зависит от Severity
+	Weight   *float64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Category string   `json:"category,omitempty" yaml:"category,omitempty"`
+	Comment  string   `json:"comment,omitempty" yaml:"comment,omitempty"`
+	// Disabled - правило хранится в словаре, но не применяется
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// Exceptions - слова и фразы, внутри которых срабатывание этого правила не считается нарушением
+	Exceptions []string `json:"exceptions,omitempty" yaml:"exceptions,omitempty"`
+}
+
+// reservedRuleIDs - пути административного API /rules/{id}, которые не могут быть идентификаторами правил
+var reservedRuleIDs = map[string]bool{"audit": true, "dry-run": true}
+
+// RuleSet - загруженный словарь. После загрузки не изменяется,
+// поэтому его можно читать из нескольких горутин без блокировок.
+type RuleSet struct {
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Rules   []Rule `json:"rules" yaml:"rules"`
+	// Policies дополняют и переопределяют встроенные политики
+	Policies map[string]Policy `json:"policies,omitempty" yaml:"policies,omitempty"`
+	// Heuristics - настройки эвристических проверок (ссылки, капс, повторы и т.п.)
+	Heuristics *Heuristics `json:"heuristics,omitempty" yaml:"heuristics,omitempty"`
+	// PersonalData - настройки детекторов персональных данных (телефоны, email, карты, паспорта)
+	PersonalData *PersonalData `json:"personal_data,omitempty" yaml:"personal_data,omitempty"`
+	// Allowlist - исключения для всех правил: слова, внутри которых совпадения не считаются нарушениями, и ссылки
+	Allowlist *Allowlist `json:"allowlist,omitempty" yaml:"allowlist,omitempty"`
+
+	// patterns - нормализованные термины правил, в режиме stem последнее слово заменено основой
+	// (у правила может быть несколько форм основы), matcher - автоматы по ним
+	patterns []rulePattern
+	matcher  scriptMatcher
+	checkers []Checker
+	// exceptionTerms - нормализованные исключения из Allowlist и правил, exceptions - автомат по ним
+	exceptionTerms []exceptionTerm
+	exceptions     scriptMatcher
+}
+
+// rulePattern - форма термина правила Rules[Rule] в алфавите Script
+type rulePattern struct {
+	Rule   int
+	Script int
+	Term   normalizedText
+}
+
+// RuleMatch - срабатывание правила Rules[Rule] на рунах [Start, End) нормализованного текста
+type RuleMatch struct {
+	Rule  int
+	Start int
+	End   int
+}
+
+// builtinRules - словарь, который используется, если файл не задан: запрещенные слова
+// и слова, с которыми комментарий отправляется на ручную модерацию
+//
+//go:embed rules/default.yaml
+var builtinRules []byte
+
+// defaultRuleSet разбирает встроенный словарь
+func defaultRuleSet() *RuleSet {
+	rs, err := parseRuleSet(builtinRules, ".yaml")
+	if err != nil {
+		panic(fmt.Sprintf("invalid builtin dictionary: %v", err))
+	}
+	rs.Version = "builtin"
+	return rs
+}
+
+// loadRuleSet читает словарь из файла. Формат определяется по расширению:
+// .json и .yaml/.yml - словарь с метаданными, остальные - текст, по одному слову в строке.
+func loadRuleSet(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dictionary: %w", err)
+	}
+	return parseRuleSet(data, filepath.Ext(path))
+}
+
+func parseRuleSet(data []byte, ext string) (*RuleSet, error) {
+	var rs RuleSet
+	switch strings.ToLower(ext) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rs); err != nil {
+			return nil, fmt.Errorf("failed to parse json dictionary: %w", err)
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&rs); err != nil {
+			return nil, fmt.Errorf("failed to parse yaml dictionary: %w", err)
+		}
+	default:
+		rules, err := parseTextRules(data)
+		if err != nil {
+			return nil, err
+		}
+		rs.Rules = rules
+	}
+
+	if rs.Version == "" {
+		sum := sha256.Sum256(data)
+		rs.Version = hex.EncodeToString(sum[:6])
+	}
+
+	if err := rs.validate(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// parseTextRules разбирает текстовый словарь: одно слово в строке, # - комментарий
+func parseTextRules(data []byte) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, Rule{ID: line, Term: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse text dictionary: %w", err)
+	}
+	return rules, nil
+}
+
+// validate проверяет словарь, заполняет значения по умолчанию и нормализует термины
+func (rs *RuleSet) validate() error {
+	if len(rs.Rules) == 0 {
+		return fmt.Errorf("dictionary has no rules")
+	}
+
+	ids := make(map[string]bool, len(rs.Rules))
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		rule.Term = strings.TrimSpace(rule.Term)
+		if rule.Term == "" {
+			return fmt.Errorf("rule #%d: term is required", i+1)
+		}
+		if rule.ID == "" {
+			rule.ID = rule.Term
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule #%d: duplicate id %q", i+1, rule.ID)
+		}
+		if reservedRuleIDs[rule.ID] {
+			return fmt.Errorf("rule #%d: id %q is reserved", i+1, rule.ID)
+		}
+		ids[rule.ID] = true
+
+		switch rule.Action {
+		case "":
+			rule.Action = ActionReject
+		case ActionReject, ActionReview:
+		default:
+			return fmt.Errorf("rule %q: unknown action %q", rule.ID, rule.Action)
+		}
+
+		switch rule.Severity {
+		case "":
+			// По умолчанию серьезность определяется действием правила
+			rule.Severity = SeverityHigh
+			if rule.Action == ActionReview {
+				rule.Severity = SeverityMedium
+			}
+		case SeverityLow, SeverityMedium, SeverityHigh:
+		default:
+			return fmt.Errorf("rule %q: unknown severity %q", rule.ID, rule.Severity)
+		}
+
+		if rule.Weight == nil {
+			weight := severityWeights[rule.Severity]
+			rule.Weight = &weight
+		} else if *rule.Weight < 0 {
+			return fmt.Errorf("rule %q: weight must not be negative", rule.ID)
+		}
+
+		switch rule.Mode {
+		case "":
+			rule.Mode = MatchSubstring
+		case MatchSubstring, MatchWord, MatchStem:
+		default:
+			return fmt.Errorf("rule %q: unknown mode %q", rule.ID, rule.Mode)
+		}
+
+		term, script := normalizeTerm(rule.Term)
+		if len(term.Runes) == 0 {
+			return fmt.Errorf("rule %q: term has no letters or digits", rule.ID)
+		}
+		forms := []normalizedText{term}
+		if rule.Mode == MatchStem {
+			forms = stemForms(term)
+		}
+		for _, form := range forms {
+			rs.patterns = append(rs.patterns, rulePattern{Rule: i, Script: script, Term: form})
+		}
+	}
+
+	for name, policy := range rs.Policies {
+		if err := policy.validate(name); err != nil {
+			return err
+		}
+		rs.Policies[name] = policy
+	}
+
+	checkers, err := rs.Heuristics.checkers()
+	if err != nil {
+		return err
+	}
+	pii, err := rs.PersonalData.checkers()
+	if err != nil {
+		return err
+	}
+	rs.checkers = append(checkers, pii...)
+
+	if err := rs.buildExceptions(); err != nil {
+		return err
+	}
+
+	patterns := make([][]rune, len(rs.patterns))
+	scripts := make([]int, len(rs.patterns))
+	for i, p := range rs.patterns {
+		if !rs.Rules[p.Rule].Disabled {
+			patterns[i] = p.Term.Runes
+		}
+		scripts[i] = p.Script
+	}
+	rs.matcher = newScriptMatcher(patterns, scripts)
+	return nil
+}
+
+// stemForms заменяет последнее слово термина его основой. Для русской основы, которая кончается
+// на две согласные, добавляются формы с беглой гласной: "маск" -> "масок", "масек".
+func stemForms(term normalizedText) []normalizedText {
+	last := len(term.Runes) - 1
+	for !term.Boundary[last] {
+		last--
+	}
+	stemmed := stem(expandWord(term, last, len(term.Runes)))
+	if len(stemmed) == 0 {
+		return []normalizedText{term}
+	}
+
+	forms := []normalizedText{replaceLastWord(term, last, stemmed)}
+	if n := len(stemmed); n >= 3 && isRussianConsonant(stemmed[n-1]) && isRussianConsonant(stemmed[n-2]) {
+		for _, vowel := range []rune{'о', 'е'} {
+			fleeting := append(append(append([]rune{}, stemmed[:n-1]...), vowel), stemmed[n-1])
+			forms = append(forms, replaceLastWord(term, last, fleeting))
+		}
+	}
+	return forms
+}
+
+func isRussianConsonant(r rune) bool {
+	return unicode.Is(unicode.Cyrillic, r) && unicode.IsLetter(r) && !strings.ContainsRune(ruVowels+"йьъ", r)
+}
+
+// replaceLastWord заменяет слово термина, начинающееся с last, словом word
+func replaceLastWord(term normalizedText, last int, word []rune) normalizedText {
+	runes, counts := encodeRuns(word)
+	result := normalizedText{
+		Runes:    append(append([]rune{}, term.Runes[:last]...), runes...),
+		Counts:   append(append([]int{}, term.Counts[:last]...), counts...),
+		Boundary: append([]bool{}, term.Boundary[:last+1]...),
+		Soft:     append([]bool{}, term.Soft[:last+1]...),
+	}
+	for len(result.Boundary) < len(result.Runes) {
+		result.Boundary = append(result.Boundary, false)
+		result.Soft = append(result.Soft, false)
+	}
+	result.Boundary = append(result.Boundary, true)
+	result.Soft = append(result.Soft, false)
+	return result
+}
+
+// expandWord восстанавливает слово [start, end) с двойными буквами для стеммера.
+// Три и больше повтора считаются растягиванием слова и сводятся к одной букве.
+func expandWord(text normalizedText, start, end int) []rune {
+	var word []rune
+	for i := start; i < end; i++ {
+		word = append(word, text.Runes[i])
+		if text.Counts[i] == 2 {
+			word = append(word, text.Runes[i])
+		}
+	}
+	return word
+}
+
+// encodeRuns схлопывает повторы букв слова, как normalize
+func encodeRuns(word []rune) ([]rune, []int) {
+	var runes []rune
+	var counts []int
+	for i, r := range word {
+		if i > 0 && r == word[i-1] {
+			counts[len(counts)-1]++
+			continue
+		}
+		runes = append(runes, r)
+		counts = append(counts, 1)
+	}
+	return runes, counts
+}
+
+// Match находит все срабатывания правил в тексте за один проход по каждому алфавиту.
+// Термин ищется в варианте текста своего алфавита. Совпадение не может пересекать пробел,
+// если в самом термине нет нескольких слов, и должно содержать не меньше повторов букв, чем термин.
+// Дальше совпадение проверяется по режиму правила; знак препинания считается границей слова.
+func (rs *RuleSet) Match(texts [scriptCount]normalizedText) []RuleMatch {
+	var matches []RuleMatch
+	seen := map[RuleMatch]bool{}
+	for _, hit := range rs.matcher.FindAll(texts) {
+		p := rs.patterns[hit.Pattern]
+		text := texts[p.Script]
+		phrase := hasInnerBoundary(p.Term.Boundary, 0, len(p.Term.Runes))
+		if !phrase && hasInnerSpace(text, hit.Start, hit.End) || !coversCounts(text, p.Term, hit.Start) {
+			continue
+		}
+
+		switch rs.Rules[p.Rule].Mode {
+		case MatchWord:
+			if !text.Boundary[hit.Start] || !text.Boundary[hit.End] {
+				continue
+			}
+		case MatchStem:
+			if !text.Boundary[hit.Start] || !sameStem(text, hit.Start, hit.End) {
+				continue
+			}
+		}
+		m := RuleMatch{Rule: p.Rule, Start: hit.Start, End: hit.End}
+		if !seen[m] {
+			seen[m] = true
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// sameStem проверяет, что основа слова текста, в котором заканчивается совпадение [start, end),
+// равна найденной части этого слова, то есть основе термина
+func sameStem(text normalizedText, start, end int) bool {
+	wordStart := end - 1
+	for wordStart > start && !text.Boundary[wordStart] {
+		wordStart--
+	}
+	wordEnd := end
+	for !text.Boundary[wordEnd] {
+		wordEnd++
+	}
+	if wordEnd == end {
+		return true
+	}
+	stemmed, _ := encodeRuns(stem(expandWord(text, wordStart, wordEnd)))
+	return string(stemmed) == string(text.Runes[wordStart:end])
+}
+
+// firstMatch возвращает правило с заданным действием, стоящее в словаре раньше остальных сработавших
+func (rs *RuleSet) firstMatch(matches []RuleMatch, action string) (Rule, bool) {
+	first := -1
+	for _, m := range matches {
+		if rs.Rules[m.Rule].Action == action && (first < 0 || m.Rule < first) {
+			first = m.Rule
+		}
+	}
+	if first < 0 {
+		return Rule{}, false
+	}
+	return rs.Rules[first], true
+}
+
+// Dictionary хранит текущий словарь и подменяет его атомарно при перезагрузке.
+// Если новый файл не удалось загрузить, продолжает работать предыдущий словарь.
+type Dictionary struct {
+	path    string
+	current atomic.Pointer[RuleSet]
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+func NewDictionary(path string) (*Dictionary, error) {
+	d := &Dictionary{path: path}
+	if path == "" {
+		d.current.Store(defaultRuleSet())
+		return d, nil
+	}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Current возвращает действующий словарь
+func (d *Dictionary) Current() *RuleSet {
+	return d.current.Load()
+}
+
+// Reload перечитывает файл словаря
+func (d *Dictionary) Reload() error {
+	if d.path == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat dictionary: %w", err)
+	}
+
+	rs, err := loadRuleSet(d.path)
+	if err != nil {
+		// Запоминаем версию файла, чтобы не пытаться загружать его повторно до следующего изменения
+		d.modTime, d.size = info.ModTime(), info.Size()
+		return err
+	}
+
+	d.current.Store(rs)
+	d.modTime, d.size = info.ModTime(), info.Size()
+	slog.Info("Dictionary loaded", "path", d.path, "version", rs.Version, "rules", len(rs.Rules))
+	return nil
+}
+
+// Update применяет изменение к копии словаря версии version, проверяет результат, сохраняет его в файл
+// и подменяет текущий словарь. В файле переписываются только измененные правила, остальное содержимое,
+// включая комментарии YAML, остается как есть. Без файла и для текстового словаря изменения запрещены.
+func (d *Dictionary) Update(version string, change func(rs *RuleSet) error) (*RuleSet, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := d.Current()
+	if current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	if d.path == "" {
+		return nil, ErrNoFile
+	}
+	ext := strings.ToLower(filepath.Ext(d.path))
+	if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+		return nil, ErrReadOnly
+	}
+
+	next, err := current.clone()
+	if err != nil {
+		return nil, err
+	}
+	if err := change(next); err != nil {
+		return nil, err
+	}
+	// Измененные правила запоминаем до validate, чтобы не записать в файл значения по умолчанию
+	changed := changedRules(current, next)
+	if err := next.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRuleSet, err)
+	}
+
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dictionary: %w", err)
+	}
+	if ext == ".json" {
+		data, err = patchJSONRules(data, next.Rules, changed)
+	} else {
+		data, err = patchYAMLRules(data, next.Rules, changed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Как и при загрузке, версией становится хеш содержимого файла. Явное поле version в файле
+	// заменяется этим хешем, иначе после перезагрузки вернулась бы старая версия и устаревший
+	// If-Match снова прошел бы проверку.
+	sum := sha256.Sum256(data)
+	next.Version = hex.EncodeToString(sum[:6])
+	if ext == ".json" {
+		data, err = patchJSONVersion(data, next.Version)
+	} else {
+		data, err = patchYAMLVersion(data, next.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.save(data); err != nil {
+		return nil, err
+	}
+
+	d.current.Store(next)
+	slog.Info("Dictionary updated", "path", d.path, "version", next.Version, "rules", len(next.Rules))
+	return next, nil
+}
+
+// clone возвращает независимую копию словаря без построенного автомата
+func (rs *RuleSet) clone() (*RuleSet, error) {
+	data, err := json.Marshal(rs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy dictionary: %w", err)
+	}
+	var copied RuleSet
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, fmt.Errorf("failed to copy dictionary: %w", err)
+	}
+	return &copied, nil
+}
+
+// changedRules возвращает новые и измененные правила next по сравнению с current по идентификатору
+func changedRules(current, next *RuleSet) map[string]Rule {
+	changed := map[string]Rule{}
+	for _, rule := range next.Rules {
+		if i := current.ruleIndex(rule.ID); i < 0 || !reflect.DeepEqual(current.Rules[i], rule) {
+			changed[rule.ID] = rule
+		}
+	}
+	return changed
+}
+
+// patchYAMLRules заменяет в YAML-словаре список правил на rules: неизмененные правила берутся
+// из файла вместе с комментариями, измененные и новые записываются заново
+func patchYAMLRules(data []byte, rules []Rule, changed map[string]Rule) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse dictionary: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse dictionary: top level must be a mapping")
+	}
+	root := doc.Content[0]
+	var seq *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "rules" {
+			seq = root.Content[i+1]
+		}
+	}
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("failed to parse dictionary: rules must be a list")
+	}
+
+	existing := map[string]*yaml.Node{}
+	for _, node := range seq.Content {
+		var rule Rule
+		if err := node.Decode(&rule); err != nil {
+			return nil, fmt.Errorf("failed to parse dictionary: %w", err)
+		}
+		existing[ruleFileID(rule)] = node
+	}
+
+	content := make([]*yaml.Node, 0, len(rules))
+	for _, rule := range rules {
+		raw, ok := changed[rule.ID]
+		node := existing[rule.ID]
+		if ok || node == nil {
+			node = &yaml.Node{}
+			if err := node.Encode(raw); err != nil {
+				return nil, fmt.Errorf("failed to encode rule %q: %w", rule.ID, err)
+			}
+		}
+		content = append(content, node)
+	}
+	seq.Content = content
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// patchYAMLVersion заменяет значение поля version верхнего уровня, если оно есть в файле
+func patchYAMLVersion(data []byte, version string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse dictionary: %w", err)
+	}
+	root := doc.Content[0]
+	found := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "version" {
+			root.Content[i+1].SetString(version)
+			root.Content[i+1].Style = yaml.DoubleQuotedStyle
+			found = true
+		}
+	}
+	if !found {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// patchJSONVersion заменяет значение поля version, если оно есть в файле
+func patchJSONVersion(data []byte, version string) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse dictionary: %w", err)
+	}
+	if _, ok := doc["version"]; !ok {
+		return data, nil
+	}
+	encoded, err := json.Marshal(version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	doc["version"] = encoded
+
+	data, err = json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// patchJSONRules заменяет в JSON-словаре список правил на rules, остальные поля файла не меняются
+func patchJSONRules(data []byte, rules []Rule, changed map[string]Rule) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse dictionary: %w", err)
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(doc["rules"], &items); err != nil {
+		return nil, fmt.Errorf("failed to parse dictionary: %w", err)
+	}
+	existing := map[string]json.RawMessage{}
+	for _, item := range items {
+		var rule Rule
+		if err := json.Unmarshal(item, &rule); err != nil {
+			return nil, fmt.Errorf("failed to parse dictionary: %w", err)
+		}
+		existing[ruleFileID(rule)] = item
+	}
+
+	items = items[:0]
+	for _, rule := range rules {
+		raw, ok := changed[rule.ID]
+		item := existing[rule.ID]
+		if ok || item == nil {
+			encoded, err := json.Marshal(raw)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode rule %q: %w", rule.ID, err)
+			}
+			item = encoded
+		}
+		items = append(items, item)
+	}
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	doc["rules"] = encoded
+
+	data, err = json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dictionary: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ruleFileID - идентификатор правила из файла: как и в validate, без id им становится термин
+func ruleFileID(rule Rule) string {
+	if rule.ID != "" {
+		return rule.ID
+	}
+	return strings.TrimSpace(rule.Term)
+}
+
+// save атомарно перезаписывает файл словаря. Вызывается под d.mu.
+func (d *Dictionary) save(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), ".dictionary-*")
+	if err != nil {
+		return fmt.Errorf("failed to save dictionary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save dictionary: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save dictionary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save dictionary: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), d.path); err != nil {
+		return fmt.Errorf("failed to save dictionary: %w", err)
+	}
+
+	// Запоминаем версию файла, чтобы Watch не перечитывал только что записанный словарь
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat dictionary: %w", err)
+	}
+	d.modTime, d.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Watch перезагружает словарь, когда файл изменился
+func (d *Dictionary) Watch(ctx context.Context, interval time.Duration) {
+	if d.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(d.path)
+		if err != nil {
+			slog.Error("Failed to stat dictionary", "path", d.path, "error", err)
+			continue
+		}
+
+		d.mu.Lock()
+		changed := !info.ModTime().Equal(d.modTime) || info.Size() != d.size
+		d.mu.Unlock()
+
+		if changed {
+			if err := d.Reload(); err != nil {
+				slog.Error("Failed to reload dictionary, keeping previous version", "path", d.path, "error", err)
+			}
+		}
+	}
+}
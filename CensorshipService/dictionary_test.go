@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDictionaryUpdateKeepsYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	original := `# Словарь для теста
+rules:
+  # комментарий к правилу
+  - id: qwerty
+    term: qwerty
+  - id: asdfgh
+    term: asdfgh
+    action: review
+`
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDictionary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := d.Update(d.Current().Version, func(rs *RuleSet) error {
+		rs.Rules = append(rs.Rules, Rule{ID: "zxvbnm", Term: "zxvbnm"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(rs.Rules) != 3 {
+		t.Fatalf("rules = %d, want 3", len(rs.Rules))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	for _, want := range []string{"# Словарь для теста", "# комментарий к правилу", "id: zxvbnm"} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("saved dictionary has no %q:\n%s", want, saved)
+		}
+	}
+	// Значения по умолчанию из validate в файл не попадают
+	for _, unwanted := range []string{"severity", "weight", "mode"} {
+		if strings.Contains(saved, unwanted) {
+			t.Errorf("saved dictionary contains %q:\n%s", unwanted, saved)
+		}
+	}
+
+	reloaded, err := loadRuleSet(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.Version != rs.Version {
+		t.Errorf("reloaded version = %s, want %s", reloaded.Version, rs.Version)
+	}
+}
+
+func TestDictionaryUpdateWithoutFile(t *testing.T) {
+	d, err := NewDictionary("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Update(d.Current().Version, func(rs *RuleSet) error { return nil })
+	if !errors.Is(err, ErrNoFile) {
+		t.Errorf("err = %v, want ErrNoFile", err)
+	}
+}
+
+func TestReservedRuleIDs(t *testing.T) {
+	for _, id := range []string{"audit", "dry-run"} {
+		rs := &RuleSet{Rules: []Rule{{ID: id, Term: "qwerty"}}}
+		if err := rs.validate(); err == nil {
+			t.Errorf("rule id %q: expected error", id)
+		}
+	}
+}
+
+func TestStaleVersionAfterReload(t *testing.T) {
+	for _, tt := range []struct{ name, content string }{
+		{"rules.yaml", "version: \"1\"\nrules:\n  - id: qwerty\n    term: qwerty\n"},
+		{"rules.json", `{"version": "1", "rules": [{"id": "qwerty", "term": "qwerty"}]}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			dictionary, err = NewDictionary(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { dictionary = nil }()
+			auditLog, err = NewAuditLog("")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			put := func(version string) int {
+				req := httptest.NewRequest(http.MethodPut, "/rules/qwerty", strings.NewReader(`{"term": "qwerty", "action": "review"}`))
+				req.Header.Set("If-Match", `"`+version+`"`)
+				rec := httptest.NewRecorder()
+				handleRule(rec, req)
+				return rec.Code
+			}
+
+			if code := put("1"); code != http.StatusOK {
+				t.Fatalf("update: status %d, want 200", code)
+			}
+			updated := dictionary.Current().Version
+			if err := dictionary.Reload(); err != nil {
+				t.Fatal(err)
+			}
+			if got := dictionary.Current().Version; got != updated {
+				t.Errorf("version after reload = %s, want %s", got, updated)
+			}
+			if code := put("1"); code != http.StatusPreconditionFailed {
+				t.Errorf("stale If-Match: status %d, want 412", code)
+			}
+		})
+	}
+}
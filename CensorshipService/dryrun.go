@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultCommentsURL = "http://localhost:8082"
+
+// Адрес и токен административного API CommentsService, откуда берутся последние комментарии для пробного запуска
+var (
+	commentsURL        = defaultCommentsURL
+	commentsToken      string
+	commentsHTTPClient = &http.Client{Timeout: 60 * time.Second}
+)
+
+// DryRunItem - текст корпуса пробного запуска. Label (clean или abusive) - необязательное решение модератора
+type DryRunItem struct {
+	ID    json.RawMessage `json:"id"`
+	Text  string          `json:"text"`
+	Label string          `json:"label,omitempty"`
+}
+
+// DryRunRequest - тело POST /rules/dry-run. Rules - словарь-кандидат в формате JSON-словаря
+// или строка с YAML-словарем. Корпус задается полем Corpus или LastN - числом последних комментариев CommentsService.
+type DryRunRequest struct {
+	Rules  json.RawMessage `json:"rules"`
+	Corpus []DryRunItem    `json:"corpus,omitempty"`
+	LastN  int             `json:"last_n,omitempty"`
+	Mode   string          `json:"mode,omitempty"`
+	Policy string          `json:"policy,omitempty"`
+}
+
+// DryRunChange - вердикты текста по текущему словарю (Before) и по кандидату (After).
+// Violations - нарушения по кандидату.
+type DryRunChange struct {
+	ID         json.RawMessage `json:"id"`
+	Text       string          `json:"text"`
+	Label      string          `json:"label,omitempty"`
+	Before     string          `json:"before"`
+	After      string          `json:"after"`
+	Violations []Violation     `json:"violations,omitempty"`
+}
+
+// DryRunResponse - разница между текущим словарем и кандидатом на корпусе
+type DryRunResponse struct {
+	CurrentVersion   string `json:"current_version"`
+	CandidateVersion string `json:"candidate_version"`
+	Total            int    `json:"total"`
+	// NewlyRejected - тексты, которые кандидат отклоняет, а текущий словарь - нет
+	NewlyRejected []DryRunChange `json:"newly_rejected"`
+	// NewlyAllowed - тексты, которые отклоняет текущий словарь, но не кандидат
+	NewlyAllowed []DryRunChange `json:"newly_allowed"`
+	// Changed - остальные изменения вердикта, например allow -> review
+	Changed   []DryRunChange `json:"changed"`
+	Unchanged []DryRunChange `json:"unchanged"`
+}
+
+// parseCandidate разбирает словарь-кандидат: JSON-объект или строку с YAML
+func parseCandidate(raw json.RawMessage) (*RuleSet, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, fmt.Errorf("rules are required")
+	}
+	if raw[0] == '"' {
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, err
+		}
+		return parseRuleSet([]byte(text), ".yaml")
+	}
+	return parseRuleSet(raw, ".json")
+}
+
+// dryRun проверяет корпус текущим словарем и кандидатом и раскладывает тексты по изменению вердикта
+func dryRun(current, candidate *RuleSet, corpus []DryRunItem, mode, policy string) (DryRunResponse, error) {
+	// Политика и режим проверяются заранее, чтобы ошибка не повторялась в каждом элементе
+	for _, rules := range []*RuleSet{current, candidate} {
+		if _, _, err := checkOptions(rules, mode, policy); err != nil {
+			return DryRunResponse{}, err
+		}
+	}
+
+	items := make([]BatchItem, len(corpus))
+	for i, item := range corpus {
+		items[i] = BatchItem{ID: item.ID, Text: item.Text}
+	}
+	before := checkItems(current, items, mode, policy)
+	after := checkItems(candidate, items, mode, policy)
+
+	resp := DryRunResponse{
+		CurrentVersion:   current.Version,
+		CandidateVersion: candidate.Version,
+		Total:            len(corpus),
+		NewlyRejected:    []DryRunChange{},
+		NewlyAllowed:     []DryRunChange{},
+		Changed:          []DryRunChange{},
+		Unchanged:        []DryRunChange{},
+	}
+	for i, item := range corpus {
+		change := DryRunChange{
+			ID:         before[i].ID,
+			Text:       item.Text,
+			Label:      item.Label,
+			Before:     before[i].Verdict,
+			After:      after[i].Verdict,
+			Violations: after[i].Violations,
+		}
+		switch {
+		case change.Before == change.After:
+			change.Violations = nil
+			resp.Unchanged = append(resp.Unchanged, change)
+		case change.After == VerdictReject:
+			resp.NewlyRejected = append(resp.NewlyRejected, change)
+		case change.Before == VerdictReject:
+			resp.NewlyAllowed = append(resp.NewlyAllowed, change)
+		default:
+			resp.Changed = append(resp.Changed, change)
+		}
+	}
+	return resp, nil
+}
+
+// fetchRecentComments загружает n последних комментариев с решением модерации из CommentsService
+func fetchRecentComments(ctx context.Context, n int) ([]DryRunItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, commentsURL+"/admin/comments/export?last="+strconv.Itoa(n), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if commentsToken != "" {
+		req.Header.Set("Authorization", "Bearer "+commentsToken)
+	}
+
+	resp, err := commentsHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comments: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch comments: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var items []DryRunItem
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var item DryRunItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return nil, fmt.Errorf("failed to decode comment: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read comments: %w", err)
+	}
+	return items, nil
+}
+
+// handleDryRun - POST /rules/dry-run: что изменится, если заменить текущий словарь кандидатом
+func handleDryRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DryRunRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		invalidBody(w, err)
+		return
+	}
+	candidate, err := parseCandidate(req.Rules)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid candidate rules: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.LastN < 0 || req.LastN > maxBatchSize || len(req.Corpus) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("Corpus is too large: at most %d items allowed", maxBatchSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(req.Corpus) > 0 && req.LastN > 0 {
+		http.Error(w, "Use either corpus or last_n", http.StatusBadRequest)
+		return
+	}
+
+	corpus := req.Corpus
+	if req.LastN > 0 {
+		corpus, err = fetchRecentComments(r.Context(), req.LastN)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	resp, err := dryRun(dictionary.Current(), candidate, corpus, req.Mode, req.Policy)
+	if checkErrorCode(err) != "" {
+		writeCheckError(w, err)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -1,3 +1,7 @@
 module censorshipservice
 
 go 1.21
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require golang.org/x/text v0.14.0
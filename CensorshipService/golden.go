@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GoldenExample - эталонный пример: текст и вердикт, который словарь должен для него вынести
+type GoldenExample struct {
+	Text    string `json:"text"`
+	Verdict string `json:"verdict"`
+	Mode    string `json:"mode,omitempty"`
+	Policy  string `json:"policy,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// GoldenFailure - пример, на котором вердикт словаря разошелся с эталоном
+type GoldenFailure struct {
+	Line     int
+	Example  GoldenExample
+	Actual   string
+	Response ValidateResponse
+}
+
+func (f GoldenFailure) String() string {
+	s := fmt.Sprintf("line %d: %q: expected %s, got %s", f.Line, f.Example.Text, f.Example.Verdict, f.Actual)
+	if f.Example.Comment != "" {
+		s += " (" + f.Example.Comment + ")"
+	}
+	return s
+}
+
+// CheckGolden проверяет словарь на эталонных примерах в формате JSONL и возвращает расхождения.
+// Ошибка означает, что сами примеры не удалось прочитать.
+func CheckGolden(rules *RuleSet, golden io.Reader) ([]GoldenFailure, error) {
+	var failures []GoldenFailure
+	scanner := bufio.NewScanner(golden)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 || data[0] == '#' {
+			continue
+		}
+
+		var example GoldenExample
+		if err := json.Unmarshal(data, &example); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if _, ok := verdictRank[example.Verdict]; !ok {
+			return nil, fmt.Errorf("line %d: unknown verdict %q", line, example.Verdict)
+		}
+
+		resp, err := checkText(rules, ValidateRequest{Text: example.Text, Mode: example.Mode, Policy: example.Policy})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if resp.Verdict != example.Verdict {
+			failures = append(failures, GoldenFailure{Line: line, Example: example, Actual: resp.Verdict, Response: resp})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read golden examples: %w", err)
+	}
+	return failures, nil
+}
+
+// CheckGoldenFile загружает словарь и проверяет его на примерах из файла.
+// Предназначена для тестов: словари репозитория проверяются одним вызовом.
+func CheckGoldenFile(dictPath, goldenPath string) ([]GoldenFailure, error) {
+	rules, err := loadRuleSet(dictPath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(goldenPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open golden examples: %w", err)
+	}
+	defer f.Close()
+	return CheckGolden(rules, f)
+}
+
+// goldenPathFor - файл эталонных примеров рядом со словарем: rules/default.yaml -> rules/default.golden.jsonl
+func goldenPathFor(dictPath string) string {
+	return strings.TrimSuffix(dictPath, filepath.Ext(dictPath)) + ".golden.jsonl"
+}
+
+// runCheckRulesCommand - подкоманда "check-rules": проверяет словари на эталонных примерах.
+// Возвращает false, если есть расхождения.
+func runCheckRulesCommand(args []string) (bool, error) {
+	fs := flag.NewFlagSet("check-rules", flag.ExitOnError)
+	goldenPath := fs.String("golden", "", "Path to the JSONL golden examples, by default <dict>.golden.jsonl next to each dictionary")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: check-rules [-golden file] dictionary...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return false, fmt.Errorf("at least one dictionary is required")
+	}
+	if *goldenPath != "" && fs.NArg() > 1 {
+		return false, fmt.Errorf("-golden can be used with a single dictionary only")
+	}
+
+	ok := true
+	for _, dictPath := range fs.Args() {
+		path := *goldenPath
+		if path == "" {
+			path = goldenPathFor(dictPath)
+		}
+		failures, err := CheckGoldenFile(dictPath, path)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", dictPath, err)
+		}
+		for _, f := range failures {
+			fmt.Printf("%s: %s\n", path, f)
+		}
+		if len(failures) > 0 {
+			ok = false
+			continue
+		}
+		fmt.Printf("%s: ok\n", dictPath)
+	}
+	return ok, nil
+}
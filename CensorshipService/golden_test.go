@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestGoldenExamples проверяет словари rules/*.yaml на эталонных примерах из соседних .golden.jsonl
+func TestGoldenExamples(t *testing.T) {
+	dicts, err := filepath.Glob("rules/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dicts) == 0 {
+		t.Fatal("no dictionaries in rules/")
+	}
+	for _, dict := range dicts {
+		t.Run(filepath.Base(dict), func(t *testing.T) {
+			failures, err := CheckGoldenFile(dict, goldenPathFor(dict))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range failures {
+				t.Error(f)
+			}
+		})
+	}
+}
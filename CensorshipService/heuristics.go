@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"unicode"
+)
+
+// Источники нарушений
+const (
+	SourceDictionary = "dictionary"
+	SourceHeuristic  = "heuristic"
+)
+
+// Checker - эвристическая проверка текста, дополняющая словарь.
+// Нарушения возвращаются с позициями в рунах исходного текста.
+type Checker interface {
+	Name() string
+	Check(text []rune) []Violation
+}
+
+// HeuristicRule - общие настройки эвристики: как ее нарушение учитывается политикой
+type HeuristicRule struct {
+	Disabled bool     `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	Action   string   `json:"action,omitempty" yaml:"action,omitempty"`
+	Severity string   `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Category string   `json:"category,omitempty" yaml:"category,omitempty"`
+	Weight   *float64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// Heuristics - настройки встроенных эвристик в словаре. Эвристики включаются по одной: работают только
+// перечисленные в секции heuristics, с настройками по умолчанию для не заданных полей.
+type Heuristics struct {
+	Links      *LinksCheck      `json:"links,omitempty" yaml:"links,omitempty"`
+	Caps       *CapsCheck       `json:"caps,omitempty" yaml:"caps,omitempty"`
+	Repetition *RepetitionCheck `json:"repetition,omitempty" yaml:"repetition,omitempty"`
+	Length     *LengthCheck     `json:"length,omitempty" yaml:"length,omitempty"`
+	Invisible  *InvisibleCheck  `json:"invisible,omitempty" yaml:"invisible,omitempty"`
+}
+
+// heuristic - встроенная эвристика с общими настройками HeuristicRule
+type heuristic interface {
+	Checker
+	init() error
+	base() *HeuristicRule
+}
+
+// checkers возвращает включенные эвристики, заполняя значения по умолчанию
+func (h *Heuristics) checkers() ([]Checker, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	var all []heuristic
+	if h.Links != nil {
+		all = append(all, h.Links)
+	}
+	if h.Caps != nil {
+		all = append(all, h.Caps)
+	}
+	if h.Repetition != nil {
+		all = append(all, h.Repetition)
+	}
+	if h.Length != nil {
+		all = append(all, h.Length)
+	}
+	if h.Invisible != nil {
+		all = append(all, h.Invisible)
+	}
+
+	var checkers []Checker
+	for _, c := range all {
+		rule := c.base()
+		if rule.Disabled {
+			continue
+		}
+		if err := c.init(); err != nil {
+			return nil, fmt.Errorf("heuristic %q: %w", c.Name(), err)
+		}
+		if err := rule.init(c.Name()); err != nil {
+			return nil, err
+		}
+		checkers = append(checkers, c)
+	}
+	return checkers, nil
+}
+
+func (r *HeuristicRule) base() *HeuristicRule { return r }
+
+func (r *HeuristicRule) init(name string) error {
+	switch r.Action {
+	case "":
+		r.Action = ActionReview
+	case ActionReject, ActionReview:
+	default:
+		return fmt.Errorf("heuristic %q: unknown action %q", name, r.Action)
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityLow
+	case SeverityLow, SeverityMedium, SeverityHigh:
+	default:
+		return fmt.Errorf("heuristic %q: unknown severity %q", name, r.Severity)
+	}
+	if r.Category == "" {
+		r.Category = "spam"
+	}
+	if r.Weight == nil {
+		weight := severityWeights[r.Severity]
+		r.Weight = &weight
+	} else if *r.Weight < 0 {
+		return fmt.Errorf("heuristic %q: weight must not be negative", name)
+	}
+	return nil
+}
+
+// violation создает нарушение эвристики name на фрагменте [start, end)
+func (r *HeuristicRule) violation(name string, text []rune, start, end int, message string) Violation {
+	return Violation{
+		RuleID:   "heuristic:" + name,
+		Source:   SourceHeuristic,
+		Category: r.Category,
+		Severity: r.Severity,
+		Weight:   *r.Weight,
+		Action:   r.Action,
+		Match:    string(text[start:end]),
+		Start:    start,
+		End:      end,
+		Message:  message,
+	}
+}
+
+// trimmedSpan возвращает границы текста без пробелов по краям
+func trimmedSpan(text []rune) (int, int) {
+	start, end := 0, len(text)
+	for start < end && unicode.IsSpace(text[start]) {
+		start++
+	}
+	for end > start && unicode.IsSpace(text[end-1]) {
+		end--
+	}
+	return start, end
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)[^\s]+|\b[a-z0-9][a-z0-9-]*\.(?:ru|su|com|net|org|info|biz|io|me|xyz|top|рф)\b(?:/[^\s]*)?`)
+
+// LinksCheck - ссылок в тексте не больше Max (по умолчанию 2), лишние ссылки считаются нарушениями
+type LinksCheck struct {
+	HeuristicRule `yaml:",inline"`
+	Max           *int `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+func (c *LinksCheck) Name() string { return "links" }
+
+func (c *LinksCheck) init() error {
+	if c.Max == nil {
+		max := 2
+		c.Max = &max
+	}
+	if *c.Max < 0 {
+		return fmt.Errorf("max must not be negative")
+	}
+	return nil
+}
+
+func (c *LinksCheck) Check(text []rune) []Violation {
+	links := linkSpans(text)
+	if len(links) <= *c.Max {
+		return nil
+	}
+
+	var violations []Violation
+	message := fmt.Sprintf("Comment contains %d links, at most %d allowed", len(links), *c.Max)
+	for _, link := range links[*c.Max:] {
+		violations = append(violations, c.violation(c.Name(), text, link[0], link[1], message))
+	}
+	return violations
+}
+
+// CapsCheck - доля заглавных букв не больше MaxRatio (по умолчанию 0.7), если букв не меньше MinLetters (по умолчанию 10)
+type CapsCheck struct {
+	HeuristicRule `yaml:",inline"`
+	MaxRatio      float64 `json:"max_ratio,omitempty" yaml:"max_ratio,omitempty"`
+	MinLetters    int     `json:"min_letters,omitempty" yaml:"min_letters,omitempty"`
+}
+
+func (c *CapsCheck) Name() string { return "caps" }
+
+func (c *CapsCheck) init() error {
+	if c.MaxRatio == 0 {
+		c.MaxRatio = 0.7
+	}
+	if c.MinLetters == 0 {
+		c.MinLetters = 10
+	}
+	if c.MaxRatio < 0 || c.MaxRatio > 1 {
+		return fmt.Errorf("max_ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+func (c *CapsCheck) Check(text []rune) []Violation {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters < c.MinLetters {
+		return nil
+	}
+	ratio := float64(upper) / float64(letters)
+	if ratio <= c.MaxRatio {
+		return nil
+	}
+	start, end := trimmedSpan(text)
+	return []Violation{c.violation(c.Name(), text, start, end,
+		fmt.Sprintf("Comment has %.0f%% capital letters", ratio*100))}
+}
+
+// RepetitionCheck - один и тот же символ подряд не больше MaxRun раз (по умолчанию 10)
+type RepetitionCheck struct {
+	HeuristicRule `yaml:",inline"`
+	MaxRun        int `json:"max_run,omitempty" yaml:"max_run,omitempty"`
+}
+
+func (c *RepetitionCheck) Name() string { return "repetition" }
+
+func (c *RepetitionCheck) init() error {
+	if c.MaxRun == 0 {
+		c.MaxRun = 10
+	}
+	if c.MaxRun < 1 {
+		return fmt.Errorf("max_run must be positive")
+	}
+	return nil
+}
+
+func (c *RepetitionCheck) Check(text []rune) []Violation {
+	var violations []Violation
+	for start := 0; start < len(text); {
+		end := start + 1
+		for end < len(text) && unicode.ToLower(text[end]) == unicode.ToLower(text[start]) {
+			end++
+		}
+		if end-start > c.MaxRun && !unicode.IsSpace(text[start]) {
+			violations = append(violations, c.violation(c.Name(), text, start, end,
+				fmt.Sprintf("Character %q is repeated %d times", text[start], end-start)))
+		}
+		start = end
+	}
+	return violations
+}
+
+// LengthCheck - длина текста без пробелов по краям от Min (по умолчанию 1) до Max (0 - без ограничения)
+type LengthCheck struct {
+	HeuristicRule `yaml:",inline"`
+	Min           *int `json:"min,omitempty" yaml:"min,omitempty"`
+	Max           int  `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+func (c *LengthCheck) Name() string { return "length" }
+
+func (c *LengthCheck) init() error {
+	if c.Min == nil {
+		min := 1
+		c.Min = &min
+	}
+	if *c.Min < 0 || c.Max < 0 {
+		return fmt.Errorf("min and max must not be negative")
+	}
+	if c.Max > 0 && c.Max < *c.Min {
+		return fmt.Errorf("max must not be less than min")
+	}
+	// Пустой комментарий публиковать бессмысленно, поэтому по умолчанию он отклоняется
+	if c.HeuristicRule.Action == "" {
+		c.HeuristicRule.Action = ActionReject
+	}
+	if c.HeuristicRule.Severity == "" {
+		c.HeuristicRule.Severity = SeverityHigh
+	}
+	return nil
+}
+
+func (c *LengthCheck) Check(text []rune) []Violation {
+	start, end := trimmedSpan(text)
+	switch length := end - start; {
+	case length < *c.Min:
+		if length == 0 {
+			return []Violation{c.violation(c.Name(), text, 0, 0, "Comment is empty")}
+		}
+		return []Violation{c.violation(c.Name(), text, start, end,
+			fmt.Sprintf("Comment is shorter than %d characters", *c.Min))}
+	case c.Max > 0 && length > c.Max:
+		return []Violation{c.violation(c.Name(), text, start+c.Max, end,
+			fmt.Sprintf("Comment is longer than %d characters", c.Max))}
+	}
+	return nil
+}
+
+// InvisibleCheck - в тексте не больше Max (по умолчанию 0) невидимых символов: zero-width, управляющие символы направления текста и т.п.
+type InvisibleCheck struct {
+	HeuristicRule `yaml:",inline"`
+	Max           int `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+func (c *InvisibleCheck) Name() string { return "invisible" }
+
+func (c *InvisibleCheck) init() error {
+	if c.HeuristicRule.Category == "" {
+		c.HeuristicRule.Category = "obfuscation"
+	}
+	if c.Max < 0 {
+		return fmt.Errorf("max must not be negative")
+	}
+	return nil
+}
+
+func isInvisible(r rune) bool {
+	// Кроме символов форматирования - хангыль-заполнители, которые отображаются как пробел нулевой ширины
+	return unicode.Is(unicode.Cf, r) || r == '\u115f' || r == '\u1160' || r == '\u3164' || r == '\uffa0'
+}
+
+// isEmoji - символ эмодзи, модификатор цвета кожи или селектор варианта VS16
+func isEmoji(r rune) bool {
+	return r >= 0x1f000 && r <= 0x1faff || r >= 0x2600 && r <= 0x27bf || r == '\ufe0f' || unicode.Is(unicode.So, r)
+}
+
+// invisibleAt - невидимый символ, который не нужен для отображения текста. ZWJ и ZWNJ между эмодзи
+// (👨‍👩‍👧) или буквами (лигатуры в индийских и арабском письме) - часть текста и не считаются.
+func invisibleAt(text []rune, i int) bool {
+	r := text[i]
+	if !isInvisible(r) {
+		return false
+	}
+	if (r == '\u200d' || r == '\u200c') && i > 0 && i < len(text)-1 {
+		prev, next := text[i-1], text[i+1]
+		if isEmoji(prev) && isEmoji(next) {
+			return false
+		}
+		if (unicode.IsLetter(prev) || unicode.IsMark(prev)) && unicode.IsLetter(next) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *InvisibleCheck) Check(text []rune) []Violation {
+	count := 0
+	for i := range text {
+		if invisibleAt(text, i) {
+			count++
+		}
+	}
+	if count <= c.Max {
+		return nil
+	}
+
+	var violations []Violation
+	message := fmt.Sprintf("Comment contains %d invisible characters", count)
+	for start := 0; start < len(text); start++ {
+		if !invisibleAt(text, start) {
+			continue
+		}
+		end := start + 1
+		for end < len(text) && invisibleAt(text, end) {
+			end++
+		}
+		violations = append(violations, c.violation(c.Name(), text, start, end, message))
+		start = end
+	}
+	return violations
+}
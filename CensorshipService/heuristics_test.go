@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestInvisibleAllowsJoiners(t *testing.T) {
+	check := &InvisibleCheck{}
+	if _, err := (&Heuristics{Invisible: check}).checkers(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"семья 👨‍👩‍👧", 0},
+		{"сердце ❤️‍🔥", 0},
+		{"क्‍ष", 0},
+		{"qw​erty", 1},
+		{"qwerty‍", 1},
+		{"q‍w‌e​rty", 1},
+	}
+	for _, tt := range tests {
+		if got := len(check.Check([]rune(tt.text))); got != tt.want {
+			t.Errorf("%q: %d violations, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHeuristicsOptIn(t *testing.T) {
+	checkers, err := (*Heuristics)(nil).checkers()
+	if err != nil || len(checkers) != 0 {
+		t.Errorf("no heuristics section: checkers = %v, err = %v", checkers, err)
+	}
+	checkers, err = (&Heuristics{Caps: &CapsCheck{}}).checkers()
+	if err != nil || len(checkers) != 1 || checkers[0].Name() != "caps" {
+		t.Errorf("caps only: checkers = %v, err = %v", checkers, err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,20 +10,125 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"runtime"
 	"syscall"
 )
 
 const defaultPort = "8083"
 
-var forbiddenWords = []string{"qwerty", "йцукен", "zxvbnm"}
+var dictionary *Dictionary
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "train" {
+		if err := runTrainCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Training failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check-rules" {
+		ok, err := runCheckRulesCommand(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Rules check failed: %v", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
 	port := flag.String("port", defaultPort, "HTTP server port")
+	dictPath := flag.String("dict", "", "Path to the forbidden words dictionary (.txt, .json, .yaml)")
+	dictPollInterval := flag.Duration("dict-poll-interval", defaultDictionaryPollInterval, "How often the dictionary file is checked for changes")
+	flag.StringVar(&defaultPolicy, "default-policy", defaultPolicyName, "Policy applied when a request does not name one")
+	adminTokens := flag.String("admin-tokens", "", "Comma-separated name:token pairs for the admin API, empty disables the admin API")
+	auditPath := flag.String("audit-log", "", "Path to the JSONL audit log of rule changes, empty keeps it in memory")
+	batchWorkers := flag.Int("batch-workers", runtime.NumCPU(), "Number of workers validating batch and stream items")
+	flag.IntVar(&maxBatchSize, "max-batch-size", defaultMaxBatchSize, "Maximum number of items in /validate/batch")
+	flag.Int64Var(&maxBodySize, "max-body-size", defaultMaxBodySize, "Maximum request body size in bytes for /validate/batch, /rules/dry-run and /classifier/retrain")
+	modelPath := flag.String("model", "", "Path to the classifier model file, empty keeps the model in memory")
+	feedbackPath := flag.String("classifier-feedback", "", "JSONL file where classifier feedback examples are appended")
+	classifierThreshold := flag.Float64("classifier-threshold", defaultClassifierThreshold, "Classifier probability from which a comment is flagged")
+	classifierWeight := flag.Float64("classifier-weight", defaultClassifierWeight, "Weight of the classifier violation in the score")
+	flag.StringVar(&commentsURL, "comments-url", defaultCommentsURL, "CommentsService URL used by /rules/dry-run to load recent comments")
+	flag.StringVar(&commentsToken, "comments-token", "", "Admin token for the CommentsService API")
+	statsPath := flag.String("stats-file", "", "Path where hit statistics are saved, empty keeps them in memory")
+	statsInterval := flag.Duration("stats-snapshot-interval", defaultStatsSnapshotInterval, "How often hit statistics are saved to -stats-file")
+	mask := flag.String("mask-char", string(defaultMaskChar), "Character that replaces forbidden words in mask mode")
 	flag.Parse()
 
+	if maskRunes := []rune(*mask); len(maskRunes) == 1 {
+		maskChar = maskRunes[0]
+	} else {
+		log.Fatalf("-mask-char must be a single character")
+	}
+
+	var err error
+	dictionary, err = NewDictionary(*dictPath)
+	if err != nil {
+		log.Fatalf("Failed to load dictionary: %v", err)
+	}
+	if _, ok := dictionary.Current().policy(defaultPolicy); !ok {
+		log.Fatalf("Unknown default policy %q", defaultPolicy)
+	}
+
+	tokens, err := parseAdminTokens(*adminTokens)
+	if err != nil {
+		log.Fatalf("Invalid -admin-tokens: %v", err)
+	}
+	auditLog, err = NewAuditLog(*auditPath)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	if *batchWorkers < 1 {
+		log.Fatalf("-batch-workers must be positive")
+	}
+	if maxBodySize < 1 {
+		log.Fatalf("-max-body-size must be positive")
+	}
+	validationPool = NewWorkerPool(*batchWorkers)
+
+	classifier, err = NewClassifier(*modelPath, *feedbackPath, *classifierThreshold, *classifierWeight)
+	if err != nil {
+		log.Fatalf("Failed to load classifier: %v", err)
+	}
+
+	hitStats, err = NewHitStats(*statsPath)
+	if err != nil {
+		log.Fatalf("Failed to load stats: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dictionary.Watch(ctx, *dictPollInterval)
+	go hitStats.Run(ctx, *statsInterval)
+
+	// SIGHUP перечитывает словарь без перезапуска
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if err := dictionary.Reload(); err != nil {
+				slog.Error("Failed to reload dictionary, keeping previous version", "error", err)
+			}
+		}
+	}()
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/validate", handleValidate)
+	mux.HandleFunc("/validate/batch", handleValidateBatch)
+	mux.HandleFunc("/validate/stream", handleValidateStream)
+	// Административный API доступен только с токенами
+	if len(tokens) > 0 {
+		mux.Handle("/classifier", adminAuth(tokens, http.HandlerFunc(handleClassifier)))
+		mux.Handle("/classifier/feedback", adminAuth(tokens, http.HandlerFunc(handleClassifierFeedback)))
+		mux.Handle("/classifier/retrain", adminAuth(tokens, http.HandlerFunc(handleClassifierRetrain)))
+		mux.Handle("/rules", adminAuth(tokens, http.HandlerFunc(handleRules)))
+		mux.Handle("/rules/", adminAuth(tokens, http.HandlerFunc(handleRule)))
+		mux.Handle("/stats", adminAuth(tokens, http.HandlerFunc(handleStats)))
+	} else {
+		slog.Warn("Admin API is disabled: -admin-tokens is not set")
+	}
 
 	handler := requestIDMiddleware(loggingMiddleware(mux))
 
@@ -46,6 +152,9 @@ func main() {
 	if err := server.Close(); err != nil {
 		log.Printf("Error closing server: %v", err)
 	}
+	if err := hitStats.Snapshot(); err != nil {
+		log.Printf("Error saving stats: %v", err)
+	}
 }
 
 func handleValidate(w http.ResponseWriter, r *http.Request) {
@@ -60,23 +169,18 @@ func handleValidate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	text := strings.ToLower(req.Text)
-	for _, word := range forbiddenWords {
-		if strings.Contains(text, strings.ToLower(word)) {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(ValidateResponse{
-				Valid: false,
-				Error: fmt.Sprintf("Comment contains forbidden word: %s", word),
-			})
-			return
-		}
+	resp, err := checkText(dictionary.Current(), req)
+	if checkErrorCode(err) != "" {
+		writeCheckError(w, err)
+		return
 	}
+	hitStats.Record(resp)
 
+	status := http.StatusOK
+	if resp.Verdict == VerdictReject {
+		status = http.StatusBadRequest
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(ValidateResponse{
-		Valid: true,
-	})
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
 }
-
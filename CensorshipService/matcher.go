@@ -0,0 +1,143 @@
+package main
+
+import "sort"
+
+// Matcher - автомат Ахо-Корасик над рунами. Находит все вхождения всех шаблонов
+// за один проход по тексту, время не зависит от числа шаблонов.
+// После построения не изменяется и безопасен для использования из нескольких горутин.
+type Matcher struct {
+	nodes   []acNode
+	lengths []int
+}
+
+type acNode struct {
+	next map[rune]int32
+	fail int32
+	// outputs - шаблоны, которые заканчиваются в этом узле
+	outputs []int32
+	// dict - ближайший по цепочке fail узел с непустым outputs, -1 если такого нет
+	dict int32
+}
+
+// Hit - вхождение шаблона Pattern в текст, [Start, End) - индексы рун
+type Hit struct {
+	Pattern int
+	Start   int
+	End     int
+}
+
+// newMatcher строит автомат; индекс шаблона в patterns становится его идентификатором
+func newMatcher(patterns [][]rune) *Matcher {
+	m := &Matcher{
+		nodes:   []acNode{{next: map[rune]int32{}, dict: -1}},
+		lengths: make([]int, len(patterns)),
+	}
+
+	for id, pattern := range patterns {
+		m.lengths[id] = len(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+		state := int32(0)
+		for _, r := range pattern {
+			next, ok := m.nodes[state].next[r]
+			if !ok {
+				next = int32(len(m.nodes))
+				m.nodes = append(m.nodes, acNode{next: map[rune]int32{}, dict: -1})
+				m.nodes[state].next[r] = next
+			}
+			state = next
+		}
+		m.nodes[state].outputs = append(m.nodes[state].outputs, int32(id))
+	}
+
+	// Ссылки fail и dict строим обходом в ширину
+	queue := make([]int32, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[state].next {
+			fail := m.nodes[state].fail
+			for {
+				if next, ok := m.nodes[fail].next[r]; ok && next != child {
+					m.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					m.nodes[child].fail = 0
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			failNode := m.nodes[m.nodes[child].fail]
+			if len(failNode.outputs) > 0 {
+				m.nodes[child].dict = m.nodes[child].fail
+			} else {
+				m.nodes[child].dict = failNode.dict
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	return m
+}
+
+// FindAll возвращает все вхождения шаблонов в порядке их окончания в тексте
+func (m *Matcher) FindAll(text []rune) []Hit {
+	var hits []Hit
+	state := int32(0)
+	for i, r := range text {
+		for {
+			if next, ok := m.nodes[state].next[r]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = m.nodes[state].fail
+		}
+
+		for out := state; out >= 0; out = m.nodes[out].dict {
+			for _, id := range m.nodes[out].outputs {
+				hits = append(hits, Hit{Pattern: int(id), Start: i + 1 - m.lengths[id], End: i + 1})
+			}
+			if out == 0 {
+				break
+			}
+		}
+	}
+	return hits
+}
+
+// scriptMatcher - автоматы по шаблонам разных алфавитов: шаблон ищется в варианте текста своего алфавита.
+// Идентификаторы шаблонов общие для всех алфавитов.
+type scriptMatcher [scriptCount]*Matcher
+
+// newScriptMatcher строит автоматы; scripts[i] - алфавит шаблона patterns[i]
+func newScriptMatcher(patterns [][]rune, scripts []int) scriptMatcher {
+	var m scriptMatcher
+	for script := range m {
+		own := make([][]rune, len(patterns))
+		for i, pattern := range patterns {
+			if scripts[i] == script {
+				own[i] = pattern
+			}
+		}
+		m[script] = newMatcher(own)
+	}
+	return m
+}
+
+// FindAll возвращает вхождения шаблонов всех алфавитов в порядке их окончания в тексте
+func (m scriptMatcher) FindAll(texts [scriptCount]normalizedText) []Hit {
+	var hits []Hit
+	for script, matcher := range m {
+		hits = append(hits, matcher.FindAll(texts[script].Runes)...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].End < hits[j].End })
+	return hits
+}
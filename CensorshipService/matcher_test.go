@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// naiveFindAll ищет каждый шаблон отдельно через strings.Index
+func naiveFindAll(patterns [][]rune, text []rune) []Hit {
+	var hits []Hit
+	s := string(text)
+	for id, pattern := range patterns {
+		if len(pattern) == 0 {
+			continue
+		}
+		p := string(pattern)
+		for offset := 0; ; {
+			i := strings.Index(s[offset:], p)
+			if i < 0 {
+				break
+			}
+			start := len([]rune(s[:offset+i]))
+			hits = append(hits, Hit{Pattern: id, Start: start, End: start + len(pattern)})
+			// Следующий поиск - со следующей руны, чтобы найти пересекающиеся вхождения
+			_, size := utf8.DecodeRuneInString(s[offset+i:])
+			offset += i + size
+		}
+	}
+	return hits
+}
+
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].End != hits[j].End {
+			return hits[i].End < hits[j].End
+		}
+		if hits[i].Start != hits[j].Start {
+			return hits[i].Start < hits[j].Start
+		}
+		return hits[i].Pattern < hits[j].Pattern
+	})
+}
+
+func runesOf(words ...string) [][]rune {
+	patterns := make([][]rune, len(words))
+	for i, w := range words {
+		if w != "" {
+			patterns[i] = []rune(w)
+		}
+	}
+	return patterns
+}
+
+func TestMatcherAgainstNaive(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+	}{
+		{"overlapping", []string{"he", "she", "his", "hers"}, "ushers"},
+		{"nested", []string{"a", "aa", "aaa"}, "aaaa"},
+		{"suffix chain", []string{"abcd", "bcd", "cd", "d"}, "xabcdx"},
+		{"duplicates", []string{"ab", "ab", "b"}, "abab"},
+		{"empty pattern", []string{"", "qwerty"}, "qwertyqwerty"},
+		{"cyrillic", []string{"йцукен", "цук", "кен"}, "йцукенйцукен"},
+		{"no match", []string{"xyz"}, "qwerty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := runesOf(tt.patterns...)
+			got := newMatcher(patterns).FindAll([]rune(tt.text))
+			want := naiveFindAll(patterns, []rune(tt.text))
+			sortHits(got)
+			sortHits(want)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("FindAll = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMatcherRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const alphabet = "abc"
+	word := func(maxLen int) string {
+		b := make([]byte, 1+rnd.Intn(maxLen))
+		for i := range b {
+			b[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	for round := 0; round < 200; round++ {
+		words := make([]string, 1+rnd.Intn(10))
+		for i := range words {
+			words[i] = word(4)
+		}
+		patterns := runesOf(words...)
+		text := []rune(word(40))
+		got := newMatcher(patterns).FindAll(text)
+		want := naiveFindAll(patterns, text)
+		sortHits(got)
+		sortHits(want)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("patterns %q, text %q: FindAll = %v, want %v", words, string(text), got, want)
+		}
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	const alphabet = "abcdefghijklmnopqrstuvwxyzабвгдежзийклмнопрстуфхцчшщыэюя"
+	letters := []rune(alphabet)
+	word := func(n int) []rune {
+		w := make([]rune, n)
+		for i := range w {
+			w[i] = letters[rnd.Intn(len(letters))]
+		}
+		return w
+	}
+	var text []rune
+	for len(text) < 2000 {
+		text = append(append(text, word(3+rnd.Intn(8))...), ' ')
+	}
+
+	for _, n := range []int{10, 1000, 100000} {
+		patterns := make([][]rune, n)
+		for i := range patterns {
+			patterns[i] = word(4 + rnd.Intn(6))
+		}
+		m := newMatcher(patterns)
+		b.Run(fmt.Sprintf("patterns=%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(string(text))))
+			for i := 0; i < b.N; i++ {
+				m.FindAll(text)
+			}
+		})
+	}
+}
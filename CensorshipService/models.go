@@ -1,11 +1,71 @@
 package main
 
+const (
+	VerdictAllow  = "allow"
+	VerdictReview = "review"
+	VerdictReject = "reject"
+	// VerdictMask - запрещенные слова заменены маской, текст можно публиковать
+	VerdictMask = "mask"
+)
+
+const (
+	ModeReject = "reject"
+	ModeMask   = "mask"
+)
+
 type ValidateRequest struct {
 	Text string `json:"text"`
+	// Mode - reject (по умолчанию): запрещенное слово отклоняет комментарий,
+	// mask: запрещенные слова заменяются маской
+	Mode string `json:"mode,omitempty"`
+	// Policy - имя политики, по умолчанию задается флагом -default-policy
+	Policy string `json:"policy,omitempty"`
 }
 
-type ValidateResponse struct {
-	Valid bool   `json:"valid"`
-	Error string `json:"error,omitempty"`
+// Violation - срабатывание правила. [Start, End) - индексы рун исходного (не нормализованного) текста,
+// Match - исходный текст этого фрагмента
+type Violation struct {
+	RuleID string `json:"rule_id"`
+	// Source - dictionary (правило словаря), heuristic (эвристическая проверка), pii (персональные данные) или classifier
+	Source   string  `json:"source"`
+	Category string  `json:"category,omitempty"`
+	Severity string  `json:"severity"`
+	Weight   float64 `json:"weight"`
+	Action   string  `json:"action"`
+	Match    string  `json:"match"`
+	Start    int     `json:"start"`
+	End      int     `json:"end"`
+	// Message - описание нарушения эвристики
+	Message string `json:"message,omitempty"`
+	// Suppressed - совпадение попало в исключение и не учитывается в весе и вердикте.
+	// SuppressedBy - причина: url, allowlist:<слово> или exception:<слово>
+	Suppressed   bool   `json:"suppressed,omitempty"`
+	SuppressedBy string `json:"suppressed_by,omitempty"`
 }
 
+// MaskedSpan - замененный фрагмент, [Start, End) - индексы рун исходного текста
+type MaskedSpan struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	RuleID string `json:"rule_id"`
+}
+
+type ValidateResponse struct {
+	Valid bool `json:"valid"`
+	// Verdict - allow, review (комментарий допустим, но требует ручной модерации) или reject
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// RulesVersion - версия словаря, по которому выполнена проверка
+	RulesVersion string `json:"rules_version"`
+	// Score - суммарный вес нарушений, Policy - примененная политика
+	Score  float64 `json:"score"`
+	Policy string  `json:"policy"`
+	// ClassifierScore - вероятность, что комментарий оскорбительный, по мнению классификатора; нет, если модель не обучена
+	ClassifierScore *float64 `json:"classifier_score,omitempty"`
+	// Violations - все найденные нарушения в порядке их положения в тексте
+	Violations []Violation `json:"violations"`
+	// MaskedText и MaskedSpans заполняются в режиме mask
+	MaskedText  string       `json:"masked_text,omitempty"`
+	MaskedSpans []MaskedSpan `json:"masked_spans,omitempty"`
+}
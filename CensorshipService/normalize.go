@@ -0,0 +1,291 @@
+package main
+
+import (
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Алфавиты, к которым приводятся похожие буквы и leetspeak
+const (
+	scriptLatin = iota
+	scriptCyrillic
+	scriptCount
+)
+
+// normalizedText - текст, приведенный к виду для сравнения со словарем.
+//
+// Повторы одной буквы хранятся один раз: Runes[i] повторяется в тексте Counts[i] раз подряд
+// и получена из руны исходного текста с индексом Offsets[i].
+// Boundary[i] == true, если перед Runes[i] проходит граница слова, Soft[i] - если эта граница
+// из знака препинания, а не из пробела ("hi,qwerty"). Boundary и Soft имеют длину len(Runes)+1,
+// последний элемент Boundary всегда true.
+type normalizedText struct {
+	Runes    []rune
+	Counts   []int
+	Offsets  []int
+	Boundary []bool
+	Soft     []bool
+}
+
+// Похожие буквы латиницы и кириллицы. Ключи - в исходном регистре,
+// потому что часть пар (В/B, Н/H, М/M, Т/T) совпадает только в верхнем регистре.
+var cyrillicToLatin = map[rune]rune{
+	'а': 'a', 'А': 'a', 'в': 'b', 'В': 'b', 'е': 'e', 'Е': 'e', 'ё': 'e', 'Ё': 'e',
+	'к': 'k', 'К': 'k', 'м': 'm', 'М': 'm', 'н': 'h', 'Н': 'h', 'о': 'o', 'О': 'o',
+	'р': 'p', 'Р': 'p', 'с': 'c', 'С': 'c', 'т': 't', 'Т': 't', 'у': 'y', 'У': 'y',
+	'х': 'x', 'Х': 'x', 'і': 'i', 'І': 'i', 'ј': 'j', 'Ј': 'j', 'ѕ': 's', 'Ѕ': 's',
+	'ԁ': 'd', 'һ': 'h', 'ӏ': 'l',
+}
+
+var latinToCyrillic = map[rune]rune{
+	'a': 'а', 'A': 'а', 'B': 'в', 'e': 'е', 'E': 'е', 'k': 'к', 'K': 'к', 'M': 'м',
+	'H': 'н', 'o': 'о', 'O': 'о', 'p': 'р', 'P': 'р', 'c': 'с', 'C': 'с', 'T': 'т',
+	'y': 'у', 'Y': 'у', 'x': 'х', 'X': 'х',
+}
+
+// Замены цифр и символов на буквы (leetspeak) для латинских и кириллических слов
+var leetLatin = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '@': 'a', '$': 's',
+}
+
+var leetCyrillic = map[rune]rune{
+	'0': 'о', '3': 'з', '4': 'ч', '6': 'б', '8': 'в', '@': 'а',
+}
+
+// minSpelledLetters - сколько однобуквенных слов подряд склеиваются в одно ("q w e r t y")
+const minSpelledLetters = 3
+
+type token struct {
+	runes   []rune
+	offsets []int
+	// soft - слово отделено от предыдущего знаком препинания, а не пробелом
+	soft bool
+}
+
+// normalize приводит текст к каноническому виду:
+//   - NFKC (полноширинные и стилизованные буквы становятся обычными),
+//   - удаление невидимых символов и диакритики у латинских букв,
+//   - разбиение на слова по пробелам и знакам препинания,
+//   - склейка идущих подряд однобуквенных слов ("q w e r t y", "q.w.e.r.t.y"),
+//   - замена похожих букв и leetspeak на буквы алфавита ("QWЕRTY", "qw3rty"),
+//   - нижний регистр и подсчет повторов букв ("qqwweerrttyy").
+//
+// Возвращает текст, приведенный к каждому алфавиту: термин правила сравнивается с вариантом
+// своего алфавита, поэтому "ХЕРНЯ" и "хер" совпадают, хотя в "ХЕРНЯ" есть буква без латинского двойника.
+// Повторы считаются одинаково во всех вариантах, поэтому позиции рун в них совпадают.
+func normalize(text string) [scriptCount]normalizedText {
+	tokens := splitTokens(text)
+	tokens = mergeSpelledTokens(tokens)
+
+	var texts [scriptCount]normalizedText
+	var counts, offsets []int
+	var boundary, soft []bool
+	for _, tok := range tokens {
+		var folded [scriptCount][]rune
+		for s := range folded {
+			folded[s] = foldToken(tok.runes, s)
+		}
+		for i := range tok.runes {
+			if i > 0 && sameLetter(folded, i) {
+				counts[len(counts)-1]++
+				continue
+			}
+			for s := range texts {
+				texts[s].Runes = append(texts[s].Runes, folded[s][i])
+			}
+			counts = append(counts, 1)
+			offsets = append(offsets, tok.offsets[i])
+			boundary = append(boundary, i == 0)
+			soft = append(soft, i == 0 && tok.soft)
+		}
+	}
+	boundary = append(boundary, true)
+	soft = append(soft, false)
+	for s := range texts {
+		texts[s].Counts, texts[s].Offsets, texts[s].Boundary, texts[s].Soft = counts, offsets, boundary, soft
+	}
+	return texts
+}
+
+// sameLetter - руна i слова повторяет предыдущую во всех алфавитах
+func sameLetter(folded [scriptCount][]rune, i int) bool {
+	for _, runes := range folded {
+		if runes[i] != runes[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTerm нормализует термин словаря в его основном алфавите
+func normalizeTerm(term string) (normalizedText, int) {
+	script := termScript([]rune(term))
+	return normalize(term)[script], script
+}
+
+// termScript определяет основной алфавит термина по буквам, у которых нет двойников
+// в другом алфавите. Если таких букв поровну (например, все буквы "хер" есть в латинице),
+// алфавит определяется по самим буквам: кириллица, если она есть в термине.
+func termScript(runes []rune) int {
+	latin, cyrillic, hasCyrillic := 0, 0, false
+	for _, r := range runes {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			hasCyrillic = true
+			if _, ok := cyrillicToLatin[r]; !ok {
+				cyrillic++
+			}
+		case unicode.Is(unicode.Latin, r):
+			if _, ok := latinToCyrillic[r]; !ok {
+				latin++
+			}
+		}
+	}
+	if cyrillic > latin || cyrillic == latin && hasCyrillic {
+		return scriptCyrillic
+	}
+	return scriptLatin
+}
+
+// splitTokens разбивает текст на слова. Слова разделяют пробелы и прочие символы, кроме букв,
+// цифр и @ $. Невидимые символы и отдельные диакритические знаки отбрасываются без разделения слова.
+func splitTokens(text string) []token {
+	var tokens []token
+	var cur token
+	// space - после предыдущего слова был пробел или это начало текста
+	space := true
+	flush := func() {
+		if len(cur.runes) > 0 {
+			tokens = append(tokens, cur)
+		}
+		cur = token{}
+	}
+
+	index := 0
+	for _, r := range text {
+		for _, c := range norm.NFKC.String(string(r)) {
+			switch {
+			case unicode.IsSpace(c):
+				flush()
+				space = true
+			case unicode.Is(unicode.Cf, c) || unicode.Is(unicode.Mn, c):
+			default:
+				if unicode.Is(unicode.Latin, c) {
+					c = stripDiacritics(c)
+				}
+				if !isWordRune(c) {
+					flush()
+					continue
+				}
+				if len(cur.runes) == 0 {
+					cur.soft = !space
+					space = false
+				}
+				cur.runes = append(cur.runes, c)
+				cur.offsets = append(cur.offsets, index)
+			}
+		}
+		index++
+	}
+	flush()
+	return tokens
+}
+
+// stripDiacritics убирает диакритику у латинской буквы: é -> e
+func stripDiacritics(r rune) rune {
+	for _, c := range norm.NFKD.String(string(r)) {
+		return c
+	}
+	return r
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' || r == '$'
+}
+
+// mergeSpelledTokens склеивает серии однобуквенных слов: "q w e r t y" -> "qwerty"
+func mergeSpelledTokens(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); {
+		j := i
+		for j < len(tokens) && len(tokens[j].runes) == 1 {
+			j++
+		}
+		if j-i >= minSpelledLetters {
+			merged := token{soft: tokens[i].soft}
+			for _, tok := range tokens[i:j] {
+				merged.runes = append(merged.runes, tok.runes...)
+				merged.offsets = append(merged.offsets, tok.offsets...)
+			}
+			result = append(result, merged)
+			i = j
+			continue
+		}
+		result = append(result, tokens[i])
+		i++
+	}
+	return result
+}
+
+// foldToken переводит похожие буквы и leetspeak слова в алфавит script
+func foldToken(runes []rune, script int) []rune {
+	homoglyphs, leet := cyrillicToLatin, leetLatin
+	if script == scriptCyrillic {
+		homoglyphs, leet = latinToCyrillic, leetCyrillic
+	}
+
+	hasLetters := false
+	for _, r := range runes {
+		if unicode.IsLetter(r) {
+			hasLetters = true
+			break
+		}
+	}
+
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		if m, ok := homoglyphs[r]; ok {
+			r = m
+		} else if m, ok := leet[r]; ok && hasLetters {
+			// Числа без букв оставляем как есть
+			r = m
+		}
+		r = unicode.ToLower(r)
+		if r == 'ё' {
+			r = 'е'
+		}
+		folded[i] = r
+	}
+	return folded
+}
+
+// coversCounts проверяет, что каждая буква термина повторена в тексте, начиная с позиции start,
+// не меньше раз, чем в термине: "ass" не совпадает с "as", а "book" - с "bok"
+func coversCounts(text, term normalizedText, start int) bool {
+	for i, n := range term.Counts {
+		if text.Counts[start+i] < n {
+			return false
+		}
+	}
+	return true
+}
+
+// hasInnerSpace проверяет, что внутри [start, end) есть граница слова из пробела
+func hasInnerSpace(text normalizedText, start, end int) bool {
+	for i := start + 1; i < end; i++ {
+		if text.Boundary[i] && !text.Soft[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func hasInnerBoundary(boundary []bool, start, end int) bool {
+	for i := start + 1; i < end; i++ {
+		if boundary[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+)
+
+// matchedRules возвращает идентификаторы правил, сработавших на тексте
+func matchedRules(t *testing.T, rules []Rule, text string) map[string]bool {
+	t.Helper()
+	rs := &RuleSet{Rules: rules}
+	if err := rs.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	matched := map[string]bool{}
+	for _, m := range rs.Match(normalize(text)) {
+		matched[rs.Rules[m.Rule].ID] = true
+	}
+	return matched
+}
+
+func TestNormalizeEvasions(t *testing.T) {
+	tests := []struct {
+		name  string
+		term  string
+		mode  string
+		text  string
+		match bool
+	}{
+		// Похожие буквы другого алфавита
+		{"latin term, cyrillic homoglyphs", "qwerty", "", "QWЕRTY", true},
+		{"cyrillic term, latin homoglyphs", "йцукен", "", "йцyкeн", true},
+		{"all-lookalike cyrillic term, latin text", "хер", "", "xep", true},
+		{"all-lookalike cyrillic term, inflected form", "хер", "", "ХЕРНЯ", true},
+		{"all-lookalike latin term, cyrillic text", "cop", "", "сор", true},
+		{"all-lookalike latin term, mixed word", "cop", "", "соpы", true},
+
+		// Leetspeak
+		{"leet latin", "qwerty", "", "qw3rty", true},
+		{"leet cyrillic", "вор", "", "8ор", true},
+		{"digits alone are not leet", "boa", "", "804", false},
+
+		// Разделители
+		{"dots", "qwerty", "", "q.w.e.r.t.y", true},
+		{"spaces", "qwerty", "", "q w e r t y", true},
+		{"dashes inside word", "qwerty", "", "qw-er-ty", true},
+		{"zero width space", "qwerty", "", "qw​erty", true},
+		{"space splits words", "qwerty", "", "qwe rty", false},
+		{"comma before word", "qwerty", MatchWord, "hi,qwerty", true},
+		{"apostrophe after word", "qwerty", MatchWord, "qwerty's", true},
+
+		// Повторы букв
+		{"repeated letters", "qwerty", "", "qqwweerrttyy", true},
+		{"stretched letter", "qwerty", "", "qwertyyyyy", true},
+		{"double letter in term", "ass", "", "asssss", true},
+		{"double letter is required", "ass", "", "as", false},
+		{"double letter is required in word mode", "book", MatchWord, "bok", false},
+		{"double letter word mode", "book", MatchWord, "boook", true},
+
+		// NFKC и диакритика
+		{"fullwidth", "qwerty", "", "ｑｗｅｒｔｙ", true},
+		{"math bold", "qwerty", "", "𝐪𝐰𝐞𝐫𝐭𝐲", true},
+		{"diacritics", "qwerty", "", "qwérty", true},
+		{"yo", "ежик", "", "ёжик", true},
+
+		// Ложные срабатывания
+		{"word mode inside word", "qwerty", MatchWord, "qwertyuiop", false},
+		{"clean text", "qwerty", "", "Отличная статья", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched := matchedRules(t, []Rule{{ID: "rule", Term: tt.term, Mode: tt.mode}}, tt.text)
+			if matched["rule"] != tt.match {
+				t.Errorf("term %q, text %q: match = %v, want %v", tt.term, tt.text, matched["rule"], tt.match)
+			}
+		})
+	}
+}
+
+func TestTermScript(t *testing.T) {
+	tests := []struct {
+		term string
+		want int
+	}{
+		{"qwerty", scriptLatin},
+		{"йцукен", scriptCyrillic},
+		{"хер", scriptCyrillic},
+		{"cop", scriptLatin},
+		{"qwеrty", scriptLatin},
+	}
+	for _, tt := range tests {
+		if got := termScript([]rune(tt.term)); got != tt.want {
+			t.Errorf("termScript(%q) = %d, want %d", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeOffsets(t *testing.T) {
+	texts := normalize("Hi, QQWERTY!")
+	text := texts[scriptLatin]
+	if got := string(text.Runes); got != "hiqwerty" {
+		t.Fatalf("runes = %q", got)
+	}
+	if text.Counts[2] != 2 {
+		t.Errorf("count of q = %d, want 2", text.Counts[2])
+	}
+	if text.Offsets[2] != 4 {
+		t.Errorf("offset of q = %d, want 4", text.Offsets[2])
+	}
+	if !text.Boundary[2] || text.Soft[2] {
+		t.Errorf("boundary before q: boundary %v, soft %v", text.Boundary[2], text.Soft[2])
+	}
+	for s := range texts {
+		if len(texts[s].Runes) != len(text.Runes) {
+			t.Errorf("script %d: %d runes, want %d", s, len(texts[s].Runes), len(text.Runes))
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	SourcePII = "pii"
+
+	CategoryPersonalData = "personal_data"
+)
+
+// PersonalData - настройки детекторов персональных данных в словаре.
+// Не указанный детектор работает с настройками по умолчанию: reject, high, personal_data.
+type PersonalData struct {
+	Phone    *HeuristicRule `json:"phone,omitempty" yaml:"phone,omitempty"`
+	Email    *HeuristicRule `json:"email,omitempty" yaml:"email,omitempty"`
+	Card     *HeuristicRule `json:"card,omitempty" yaml:"card,omitempty"`
+	Passport *HeuristicRule `json:"passport,omitempty" yaml:"passport,omitempty"`
+}
+
+var (
+	emailPattern = regexp.MustCompile(`(?i)[\p{L}0-9._%+-]+@[\p{L}0-9-]+(?:\.[\p{L}0-9-]+)*\.\p{L}{2,}`)
+	// Кандидаты в телефоны: с кодом страны, через 8 или мобильный номер без кода. Число цифр проверяется отдельно.
+	phonePattern = regexp.MustCompile(`(?:\+\d|\b8|\b9\d)[\d\s().-]{7,20}\d\b`)
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// Десять цифр сами по себе слишком похожи на обычные числа, поэтому паспорт ищется только после
+	// слов "паспорт", "серия" или знака "№". Нарушением считается только номер (группа 1).
+	passportPattern = regexp.MustCompile(`(?i)(?:паспорт\p{L}*|серия|№)[\s:.,]*(\d{2}\s?\d{2}[\s,]*(?:(?:номер|№)[\s:.]*)?\d{6})\b`)
+)
+
+// piiChecker находит персональные данные одного вида. Если в шаблоне есть группа,
+// нарушением считается только она, а не весь найденный текст.
+type piiChecker struct {
+	rule  *HeuristicRule
+	name  string
+	find  *regexp.Regexp
+	valid func(match string) bool
+}
+
+func (c *piiChecker) Name() string { return c.name }
+
+func (c *piiChecker) Check(text []rune) []Violation {
+	s := string(text)
+	var violations []Violation
+	for _, loc := range c.find.FindAllStringSubmatchIndex(s, -1) {
+		if len(loc) >= 4 {
+			loc = loc[2:4]
+		}
+		if c.valid != nil && !c.valid(s[loc[0]:loc[1]]) {
+			continue
+		}
+		start := utf8.RuneCountInString(s[:loc[0]])
+		end := start + utf8.RuneCountInString(s[loc[0]:loc[1]])
+		v := c.rule.violation(c.name, text, start, end, "Comment contains personal data: "+c.name)
+		v.RuleID = "pii:" + c.name
+		v.Source = SourcePII
+		violations = append(violations, v)
+	}
+	return violations
+}
+
+// checkers возвращает включенные детекторы персональных данных
+func (p *PersonalData) checkers() ([]Checker, error) {
+	if p == nil {
+		p = &PersonalData{}
+	}
+	all := []struct {
+		rule  **HeuristicRule
+		name  string
+		find  *regexp.Regexp
+		valid func(string) bool
+	}{
+		{&p.Phone, "phone", phonePattern, validPhone},
+		{&p.Email, "email", emailPattern, nil},
+		{&p.Card, "card", cardPattern, validCard},
+		{&p.Passport, "passport", passportPattern, nil},
+	}
+
+	var checkers []Checker
+	for _, d := range all {
+		if *d.rule == nil {
+			*d.rule = &HeuristicRule{}
+		}
+		rule := *d.rule
+		if rule.Disabled {
+			continue
+		}
+		if rule.Action == "" {
+			rule.Action = ActionReject
+		}
+		if rule.Severity == "" {
+			rule.Severity = SeverityHigh
+		}
+		if rule.Category == "" {
+			rule.Category = CategoryPersonalData
+		}
+		if err := rule.init("pii:" + d.name); err != nil {
+			return nil, err
+		}
+		checkers = append(checkers, &piiChecker{rule: rule, name: d.name, find: d.find, valid: d.valid})
+	}
+	return checkers, nil
+}
+
+func digitsOf(s string) []int {
+	var digits []int
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	return digits
+}
+
+// validPhone: российский номер - 11 цифр с +7 или 8, мобильный без кода - 10 цифр, международный - от 10 до 15 цифр
+func validPhone(s string) bool {
+	digits := digitsOf(s)
+	switch {
+	case s[0] == '+' && digits[0] == 7:
+		return len(digits) == 11
+	case s[0] == '+':
+		return len(digits) >= 10 && len(digits) <= 15
+	case digits[0] == 8:
+		return len(digits) == 11
+	default:
+		return len(digits) == 10
+	}
+}
+
+// validCard проверяет номер карты по алгоритму Луна
+func validCard(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
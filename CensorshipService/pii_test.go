@@ -0,0 +1,110 @@
+package main
+
+import "testing"
+
+// piiCheck возвращает детектор персональных данных name с настройками по умолчанию
+func piiCheck(t *testing.T, name string) Checker {
+	t.Helper()
+	checkers, err := (&PersonalData{}).checkers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range checkers {
+		if c.Name() == name {
+			return c
+		}
+	}
+	t.Fatalf("no %s checker", name)
+	return nil
+}
+
+// testPII проверяет, какой фрагмент каждого текста детектор считает нарушением; пустой want - нарушений нет
+func testPII(t *testing.T, name string, tests []struct{ text, want string }) {
+	t.Helper()
+	check := piiCheck(t, name)
+	for _, tt := range tests {
+		text := []rune(tt.text)
+		got := ""
+		if v := check.Check(text); len(v) > 0 {
+			got = string(text[v[0].Start:v[0].End])
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: found %q, want %q", name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPassportNeedsContext(t *testing.T) {
+	testPII(t, "passport", []struct{ text, want string }{
+		{"паспорт 45 08 123456", "45 08 123456"},
+		{"Серия 4508 номер 123456", "4508 номер 123456"},
+		{"№ 4508123456", "4508123456"},
+		{"заказ 4508123456 готов", ""},
+		{"45 08 123456", ""},
+	})
+}
+
+func TestPhone(t *testing.T) {
+	testPII(t, "phone", []struct{ text, want string }{
+		{"звоните +7 (912) 345-67-89", "+7 (912) 345-67-89"},
+		{"звоните +7-912-345-67-89", "+7-912-345-67-89"},
+		{"звоните 8 912 345 67 89", "8 912 345 67 89"},
+		{"звоните 89123456789", "89123456789"},
+		{"звоните 8 (912) 345.67.89 вечером", "8 (912) 345.67.89"},
+		{"мобильный 912 345 67 89", "912 345 67 89"},
+		{"London +44 20 7946 0958", "+44 20 7946 0958"},
+		// Похоже на телефон, но не хватает или слишком много цифр
+		{"звоните +7 912 345 67 8", ""},
+		{"звоните 8 912 345 67 899", ""},
+		{"счет 89123456789012", ""},
+		{"звоните 8 912 345", ""},
+		// Обычные числа
+		{"в 2024 году 1500 участников", ""},
+		{"рост 8%, выручка 912 млн", ""},
+		{"версия 9.12.3", ""},
+	})
+}
+
+func TestEmail(t *testing.T) {
+	testPII(t, "email", []struct{ text, want string }{
+		{"пишите на ivan.petrov@mail.ru", "ivan.petrov@mail.ru"},
+		{"пишите на test+tag@example.co.uk.", "test+tag@example.co.uk"},
+		{"пишите на Ivan_P@Example.COM", "Ivan_P@Example.COM"},
+		{"пишите на иван@почта.рф", "иван@почта.рф"},
+		{"пишите @ivan в телеграм", ""},
+		{"user@localhost", ""},
+		{"a@b.c", ""},
+		{"встреча в 10@офис", ""},
+	})
+}
+
+func TestCardLuhn(t *testing.T) {
+	testPII(t, "card", []struct{ text, want string }{
+		{"карта 4111 1111 1111 1111", "4111 1111 1111 1111"},
+		{"карта 4111-1111-1111-1111", "4111-1111-1111-1111"},
+		{"карта 5500000000000004", "5500000000000004"},
+		{"amex 378282246310005", "378282246310005"},
+		// Не проходят проверку Луна
+		{"карта 4111 1111 1111 1112", ""},
+		{"карта 5500000000000005", ""},
+		{"заказ 1234 5678 9012 3456", ""},
+		// Слишком короткие
+		{"код 4111 1111", ""},
+		{"номер 411111111111", ""},
+	})
+}
+
+func TestPIIDisabled(t *testing.T) {
+	checkers, err := (&PersonalData{Email: &HeuristicRule{Disabled: true}}).checkers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range checkers {
+		if c.Name() == "email" {
+			t.Error("disabled email checker is enabled")
+		}
+	}
+	if len(checkers) != 3 {
+		t.Errorf("%d checkers, want 3", len(checkers))
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const defaultPolicyName = "default"
+
+// Веса правил по умолчанию в зависимости от серьезности
+var severityWeights = map[string]float64{
+	SeverityLow:    1,
+	SeverityMedium: 3,
+	SeverityHigh:   10,
+}
+
+// verdictRank упорядочивает вердикты по строгости
+var verdictRank = map[string]int{
+	VerdictAllow:  0,
+	VerdictMask:   1,
+	VerdictReview: 2,
+	VerdictReject: 3,
+}
+
+// Threshold - вердикт для суммарного веса нарушений не меньше MinScore
+type Threshold struct {
+	MinScore float64 `json:"min_score" yaml:"min_score"`
+	Verdict  string  `json:"verdict" yaml:"verdict"`
+}
+
+// Policy переводит найденные нарушения в вердикт.
+// Без Thresholds вердикт определяется действиями сработавших правил (reject/review).
+// Categories задает вердикт для нарушений категории: они не учитываются в весе и действиях,
+// итоговым считается самый строгий из вердиктов.
+type Policy struct {
+	Thresholds []Threshold       `json:"thresholds,omitempty" yaml:"thresholds,omitempty"`
+	Categories map[string]string `json:"categories,omitempty" yaml:"categories,omitempty"`
+}
+
+// builtinPolicies доступны всегда, словарь может их переопределить
+var builtinPolicies = map[string]Policy{
+	defaultPolicyName: {},
+	"strict": {
+		Thresholds: []Threshold{
+			{MinScore: 1, Verdict: VerdictReview},
+			{MinScore: 10, Verdict: VerdictReject},
+		},
+		Categories: map[string]string{"hate": VerdictReject},
+	},
+	"relaxed": {
+		Thresholds: []Threshold{
+			{MinScore: 5, Verdict: VerdictMask},
+			{MinScore: 30, Verdict: VerdictReject},
+		},
+	},
+}
+
+func (p *Policy) validate(name string) error {
+	for _, t := range p.Thresholds {
+		if _, ok := verdictRank[t.Verdict]; !ok {
+			return fmt.Errorf("policy %q: unknown verdict %q", name, t.Verdict)
+		}
+		// Порог 0 срабатывал бы на любой текст, в том числе без нарушений
+		if t.MinScore <= 0 {
+			return fmt.Errorf("policy %q: min_score must be positive", name)
+		}
+	}
+	sort.SliceStable(p.Thresholds, func(i, j int) bool { return p.Thresholds[i].MinScore < p.Thresholds[j].MinScore })
+
+	for category, verdict := range p.Categories {
+		if _, ok := verdictRank[verdict]; !ok {
+			return fmt.Errorf("policy %q: unknown verdict %q for category %q", name, verdict, category)
+		}
+	}
+	return nil
+}
+
+// verdict возвращает вердикт политики для нарушений, подавленные исключениями не учитываются
+func (p *Policy) verdict(violations []Violation) string {
+	verdict := VerdictAllow
+	raise := func(v string) {
+		if verdictRank[v] > verdictRank[verdict] {
+			verdict = v
+		}
+	}
+
+	var rest []Violation
+	for _, v := range violations {
+		if v.Suppressed {
+			continue
+		}
+		if override, ok := p.Categories[v.Category]; ok {
+			raise(override)
+			continue
+		}
+		rest = append(rest, v)
+	}
+
+	if len(p.Thresholds) == 0 {
+		for _, v := range rest {
+			raise(v.Action)
+		}
+		return verdict
+	}
+
+	score := totalScore(rest)
+	byScore := VerdictAllow
+	for _, t := range p.Thresholds {
+		if score >= t.MinScore {
+			byScore = t.Verdict
+		}
+	}
+	raise(byScore)
+	return verdict
+}
+
+// policy ищет политику сначала в словаре, затем среди встроенных
+func (rs *RuleSet) policy(name string) (*Policy, bool) {
+	if p, ok := rs.Policies[name]; ok {
+		return &p, true
+	}
+	if p, ok := builtinPolicies[name]; ok {
+		return &p, true
+	}
+	return nil, false
+}
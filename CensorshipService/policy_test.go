@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestPolicyValidateMinScore(t *testing.T) {
+	tests := []struct {
+		minScore float64
+		wantErr  bool
+	}{
+		{-1, true},
+		{0, true},
+		{0.5, false},
+		{10, false},
+	}
+	for _, tt := range tests {
+		p := Policy{Thresholds: []Threshold{{MinScore: tt.minScore, Verdict: VerdictReview}}}
+		if err := p.validate("test"); (err != nil) != tt.wantErr {
+			t.Errorf("min_score %g: err = %v, want error %v", tt.minScore, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPolicyZeroScoreAllows(t *testing.T) {
+	p := Policy{Thresholds: []Threshold{{MinScore: 1, Verdict: VerdictReview}}}
+	if err := p.validate("test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.verdict(nil); got != VerdictAllow {
+		t.Errorf("no violations: verdict %q, want %q", got, VerdictAllow)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 1000
+)
+
+var auditLog *AuditLog
+
+// parseAdminTokens разбирает список "имя:токен,имя:токен" в отображение токен -> имя
+func parseAdminTokens(value string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(item, ":")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("admin token must be in the form name:token, got %q", item)
+		}
+		tokens[token] = name
+	}
+	return tokens, nil
+}
+
+// adminAuth пропускает запрос только с заголовком Authorization: Bearer <token> и передает
+// имя владельца токена в заголовке X-Admin-Actor. Без токенов все запросы отклоняются.
+func adminAuth(tokens map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		actor := ""
+		for token, name := range tokens {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+				actor = name
+			}
+		}
+		if actor == "" {
+			http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		r.Header.Set("X-Admin-Actor", actor)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RuleListResponse - ответ GET /rules
+type RuleListResponse struct {
+	Version string `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+// handleRules - GET /rules (список правил) и POST /rules (новое правило)
+func handleRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules := dictionary.Current()
+		writeVersioned(w, http.StatusOK, rules.Version, RuleListResponse{Version: rules.Version, Rules: rules.Rules})
+	case http.MethodPost:
+		var rule Rule
+		if !decodeRule(w, r, &rule) {
+			return
+		}
+		if rule.ID == "" {
+			rule.ID = strings.TrimSpace(rule.Term)
+		}
+		updateRule(w, r, "create", rule.ID, func(rs *RuleSet) (*Rule, *Rule, error) {
+			if rs.ruleIndex(rule.ID) >= 0 {
+				return nil, nil, ErrRuleExists
+			}
+			rs.Rules = append(rs.Rules, rule)
+			return nil, &rs.Rules[len(rs.Rules)-1], nil
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRule - GET, PUT и DELETE /rules/{id}. Правило отключается через PUT с "disabled": true.
+func handleRule(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/rules/")
+	if id == "" {
+		http.Error(w, "Rule id is required", http.StatusNotFound)
+		return
+	}
+	if id == "audit" {
+		handleAudit(w, r)
+		return
+	}
+	if id == "dry-run" {
+		handleDryRun(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rules := dictionary.Current()
+		i := rules.ruleIndex(id)
+		if i < 0 {
+			http.Error(w, ErrRuleNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		writeVersioned(w, http.StatusOK, rules.Version, rules.Rules[i])
+	case http.MethodPut:
+		var rule Rule
+		if !decodeRule(w, r, &rule) {
+			return
+		}
+		if rule.ID != "" && rule.ID != id {
+			http.Error(w, "Rule id in body does not match the path", http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		updateRule(w, r, "update", id, func(rs *RuleSet) (*Rule, *Rule, error) {
+			i := rs.ruleIndex(id)
+			if i < 0 {
+				return nil, nil, ErrRuleNotFound
+			}
+			before := rs.Rules[i]
+			rs.Rules[i] = rule
+			return &before, &rs.Rules[i], nil
+		})
+	case http.MethodDelete:
+		updateRule(w, r, "delete", id, func(rs *RuleSet) (*Rule, *Rule, error) {
+			i := rs.ruleIndex(id)
+			if i < 0 {
+				return nil, nil, ErrRuleNotFound
+			}
+			before := rs.Rules[i]
+			rs.Rules = append(rs.Rules[:i], rs.Rules[i+1:]...)
+			return &before, nil, nil
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAudit - GET /rules/audit?limit=N&rule_id=...
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultAuditLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			http.Error(w, fmt.Sprintf("limit must be an integer between 1 and %d", maxAuditLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(auditLog.Recent(limit, r.URL.Query().Get("rule_id")))
+}
+
+func decodeRule(w http.ResponseWriter, r *http.Request, rule *Rule) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// updateRule изменяет словарь с проверкой версии из If-Match и записывает изменение в журнал.
+// change возвращает правило до и после изменения.
+func updateRule(w http.ResponseWriter, r *http.Request, action, ruleID string, change func(rs *RuleSet) (*Rule, *Rule, error)) {
+	ifMatch := strings.Trim(r.Header.Get("If-Match"), `"`)
+	if ifMatch == "" {
+		http.Error(w, "If-Match header with the dictionary version is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	var before, after *Rule
+	rs, err := dictionary.Update(ifMatch, func(rs *RuleSet) error {
+		var err error
+		before, after, err = change(rs)
+		return err
+	})
+	switch {
+	case errors.Is(err, ErrVersionMismatch):
+		w.Header().Set("ETag", etag(dictionary.Current().Version))
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	case errors.Is(err, ErrRuleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrRuleExists), errors.Is(err, ErrReadOnly), errors.Is(err, ErrNoFile):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrInvalidRuleSet):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to update dictionary: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// after указывает на правило в копии до validate, берем итоговое правило со значениями по умолчанию
+	if after != nil {
+		if i := rs.ruleIndex(after.ID); i >= 0 {
+			after = &rs.Rules[i]
+		}
+	}
+
+	entry := AuditEntry{
+		Time:            time.Now().UTC(),
+		Actor:           r.Header.Get("X-Admin-Actor"),
+		Action:          action,
+		RuleID:          ruleID,
+		Before:          before,
+		After:           after,
+		PreviousVersion: ifMatch,
+		Version:         rs.Version,
+		RequestID:       r.Header.Get("X-Request-ID"),
+	}
+	if err := auditLog.Record(entry); err != nil {
+		slog.Error("Failed to write audit log", "error", err, "request_id", entry.RequestID)
+	}
+
+	status := http.StatusOK
+	if action == "create" {
+		status = http.StatusCreated
+	}
+	if after == nil {
+		w.Header().Set("ETag", etag(rs.Version))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeVersioned(w, status, rs.Version, after)
+}
+
+func (rs *RuleSet) ruleIndex(id string) int {
+	for i, rule := range rs.Rules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+func writeVersioned(w http.ResponseWriter, status int, version string, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(version))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
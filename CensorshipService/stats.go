@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStatsSnapshotInterval = time.Minute
+	defaultStatsTopLimit         = 20
+	// statsRetention - сколько хранятся минутные счетчики: две недели, чтобы сравнить неделю с предыдущей
+	statsRetention = 14 * 24 * time.Hour
+)
+
+// statsWindows - окна, за которые отдается статистика
+var statsWindows = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+var hitStats *HitStats
+
+// statsBucket - счетчики проверок за одну минуту
+type statsBucket struct {
+	Minute     int64          `json:"minute"`
+	Total      int            `json:"total"`
+	Verdicts   map[string]int `json:"verdicts"`
+	Rules      map[string]int `json:"rules"`
+	Categories map[string]int `json:"categories"`
+}
+
+func (b *statsBucket) add(other *statsBucket) {
+	b.Total += other.Total
+	for k, n := range other.Verdicts {
+		b.Verdicts[k] += n
+	}
+	for k, n := range other.Rules {
+		b.Rules[k] += n
+	}
+	for k, n := range other.Categories {
+		b.Categories[k] += n
+	}
+}
+
+func newStatsBucket(minute int64) *statsBucket {
+	return &statsBucket{Minute: minute, Verdicts: map[string]int{}, Rules: map[string]int{}, Categories: map[string]int{}}
+}
+
+// HitStats считает проверки по минутам: вердикты, сработавшие правила и категории нарушений.
+// Счетчики хранятся в памяти и периодически сохраняются в файл, чтобы пережить перезапуск.
+type HitStats struct {
+	path string
+
+	mu      sync.Mutex
+	buckets map[int64]*statsBucket
+	// since - время первого учтенного счетчика: правила, не сработавшие с тех пор, считаются неиспользуемыми
+	since time.Time
+	dirty bool
+}
+
+// statsSnapshot - формат файла со счетчиками
+type statsSnapshot struct {
+	Since   time.Time      `json:"since"`
+	Buckets []*statsBucket `json:"buckets"`
+}
+
+// NewHitStats загружает счетчики из файла. Без файла счетчики живут до перезапуска.
+func NewHitStats(path string) (*HitStats, error) {
+	s := &HitStats{path: path, buckets: map[int64]*statsBucket{}, since: time.Now().UTC()}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats: %w", err)
+	}
+	var snapshot statsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse stats: %w", err)
+	}
+	for _, b := range snapshot.Buckets {
+		s.buckets[b.Minute] = b
+	}
+	if !snapshot.Since.IsZero() {
+		s.since = snapshot.Since
+	}
+	s.prune(time.Now())
+	return s, nil
+}
+
+// skipStats - пакетная проверка с заголовком X-Skip-Stats: true (так проверяет повторная модерация) не учитывается
+// в статистике. На /validate заголовок не действует, иначе любой клиент мог бы скрыть свои проверки.
+func skipStats(r *http.Request) bool {
+	return r.Header.Get("X-Skip-Stats") == "true"
+}
+
+// Record учитывает результат проверки. Правило и категория учитываются один раз на текст,
+// нарушения, подавленные исключениями, не учитываются.
+func (s *HitStats) Record(resp ValidateResponse) {
+	s.recordAt(resp, time.Now())
+}
+
+func (s *HitStats) recordAt(resp ValidateResponse, now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	minute := now.Unix() / 60
+	b, ok := s.buckets[minute]
+	if !ok {
+		b = newStatsBucket(minute)
+		s.buckets[minute] = b
+	}
+	b.Total++
+	b.Verdicts[resp.Verdict]++
+
+	rules := map[string]bool{}
+	categories := map[string]bool{}
+	for _, v := range resp.Violations {
+		if v.Suppressed {
+			continue
+		}
+		rules[v.RuleID] = true
+		if v.Category != "" {
+			categories[v.Category] = true
+		}
+	}
+	for id := range rules {
+		b.Rules[id]++
+	}
+	for category := range categories {
+		b.Categories[category]++
+	}
+	s.dirty = true
+}
+
+// prune удаляет счетчики старше statsRetention. Вызывается под s.mu или до начала работы.
+func (s *HitStats) prune(now time.Time) {
+	oldest := now.Add(-statsRetention).Unix() / 60
+	for minute := range s.buckets {
+		if minute < oldest {
+			delete(s.buckets, minute)
+		}
+	}
+}
+
+// sum складывает счетчики за минуты [from, to)
+func (s *HitStats) sum(from, to time.Time) *statsBucket {
+	total := newStatsBucket(0)
+	first, last := from.Unix()/60, to.Unix()/60
+	for minute, b := range s.buckets {
+		if minute >= first && minute < last {
+			total.add(b)
+		}
+	}
+	return total
+}
+
+// Snapshot атомарно сохраняет счетчики в файл, если они изменились с прошлого сохранения
+func (s *HitStats) Snapshot() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	if !s.dirty {
+		return nil
+	}
+
+	snapshot := statsSnapshot{Since: s.since, Buckets: make([]*statsBucket, 0, len(s.buckets))}
+	for _, b := range s.buckets {
+		snapshot.Buckets = append(snapshot.Buckets, b)
+	}
+	sort.Slice(snapshot.Buckets, func(i, j int) bool { return snapshot.Buckets[i].Minute < snapshot.Buckets[j].Minute })
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".stats-*")
+	if err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// Run сохраняет счетчики раз в interval до остановки контекста
+func (s *HitStats) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				slog.Error("Failed to save stats", "error", err)
+			}
+		}
+	}
+}
+
+// RuleHits - число текстов, в которых сработало правило, за окно и за предыдущее окно такой же длины
+type RuleHits struct {
+	RuleID   string `json:"rule_id"`
+	Hits     int    `json:"hits"`
+	Previous int    `json:"previous"`
+}
+
+// StatsResponse - ответ GET /stats
+type StatsResponse struct {
+	Window string    `json:"window"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Since - с какого момента ведется статистика; если позже From, окно учтено не полностью
+	Since      time.Time      `json:"since"`
+	Total      int            `json:"total"`
+	Verdicts   map[string]int `json:"verdicts"`
+	Categories map[string]int `json:"categories"`
+	// TopRules - самые частые правила; Previous позволяет заметить рост
+	TopRules []RuleHits `json:"top_rules"`
+	// Rising - правила, которые срабатывали чаще, чем в предыдущем окне, по убыванию прироста
+	Rising []RuleHits `json:"rising"`
+	// DeadRules - включенные правила текущего словаря, которые не сработали ни разу за окно
+	DeadRules []string `json:"dead_rules"`
+}
+
+// Report собирает статистику за окно, заканчивающееся в now
+func (s *HitStats) Report(rules *RuleSet, window string, now time.Time, limit int) StatsResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	duration := statsWindows[window]
+	to := now.Truncate(time.Minute).Add(time.Minute)
+	from := to.Add(-duration)
+	current := s.sum(from, to)
+	previous := s.sum(from.Add(-duration), from)
+
+	resp := StatsResponse{
+		Window:     window,
+		From:       from.UTC(),
+		To:         to.UTC(),
+		Since:      s.since,
+		Total:      current.Total,
+		Verdicts:   current.Verdicts,
+		Categories: current.Categories,
+		TopRules:   []RuleHits{},
+		Rising:     []RuleHits{},
+		DeadRules:  []string{},
+	}
+
+	var hits []RuleHits
+	for id, n := range current.Rules {
+		hits = append(hits, RuleHits{RuleID: id, Hits: n, Previous: previous.Rules[id]})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Hits != hits[j].Hits {
+			return hits[i].Hits > hits[j].Hits
+		}
+		return hits[i].RuleID < hits[j].RuleID
+	})
+	for _, h := range hits {
+		if h.Hits > h.Previous {
+			resp.Rising = append(resp.Rising, h)
+		}
+	}
+	sort.SliceStable(resp.Rising, func(i, j int) bool {
+		return resp.Rising[i].Hits-resp.Rising[i].Previous > resp.Rising[j].Hits-resp.Rising[j].Previous
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	if len(resp.Rising) > limit {
+		resp.Rising = resp.Rising[:limit]
+	}
+	resp.TopRules = append(resp.TopRules, hits...)
+
+	for _, rule := range rules.Rules {
+		if !rule.Disabled && current.Rules[rule.ID] == 0 {
+			resp.DeadRules = append(resp.DeadRules, rule.ID)
+		}
+	}
+	return resp
+}
+
+// handleStats - GET /stats?window=hour|day|week&limit=N
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	window := query.Get("window")
+	if window == "" {
+		window = "day"
+	}
+	if _, ok := statsWindows[window]; !ok {
+		http.Error(w, "window must be hour, day or week", http.StatusBadRequest)
+		return
+	}
+	limit := defaultStatsTopLimit
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hitStats.Report(dictionary.Current(), window, time.Now(), limit))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hit - результат проверки, в котором сработали правила ruleIDs
+func hit(verdict string, ruleIDs ...string) ValidateResponse {
+	resp := ValidateResponse{Verdict: verdict}
+	for _, id := range ruleIDs {
+		resp.Violations = append(resp.Violations, Violation{RuleID: id, Category: "test"})
+	}
+	return resp
+}
+
+func TestStatsWindows(t *testing.T) {
+	s, err := NewHitStats("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.recordAt(hit(VerdictReject, "a", "a"), now.Add(-30*time.Minute))
+	s.recordAt(hit(VerdictAllow), now.Add(-2*time.Hour))
+	s.recordAt(hit(VerdictReview, "b"), now.Add(-3*24*time.Hour))
+	s.recordAt(hit(VerdictReject, "a"), now.Add(-10*24*time.Hour))
+
+	tests := []struct {
+		window  string
+		total   int
+		rejects int
+	}{
+		{"hour", 1, 1},
+		{"day", 2, 1},
+		{"week", 3, 1},
+	}
+	rules := &RuleSet{}
+	for _, tt := range tests {
+		resp := s.Report(rules, tt.window, now, defaultStatsTopLimit)
+		if resp.Total != tt.total || resp.Verdicts[VerdictReject] != tt.rejects {
+			t.Errorf("%s: total %d, rejects %d; want %d, %d", tt.window, resp.Total, resp.Verdicts[VerdictReject], tt.total, tt.rejects)
+		}
+	}
+
+	// Правило учитывается один раз на текст
+	resp := s.Report(rules, "hour", now, defaultStatsTopLimit)
+	if want := []RuleHits{{RuleID: "a", Hits: 1}}; !reflect.DeepEqual(resp.TopRules, want) {
+		t.Errorf("hour top rules %+v, want %+v", resp.TopRules, want)
+	}
+	if resp.Categories["test"] != 1 {
+		t.Errorf("hour categories %v, want test: 1", resp.Categories)
+	}
+}
+
+func TestStatsRising(t *testing.T) {
+	s, _ := NewHitStats("")
+	now := time.Now()
+	current, previous := now.Add(-10*time.Minute), now.Add(-70*time.Minute)
+	for i := 0; i < 3; i++ {
+		s.recordAt(hit(VerdictReject, "a"), current)
+	}
+	s.recordAt(hit(VerdictReject, "a"), previous)
+	s.recordAt(hit(VerdictReject, "b"), current)
+	s.recordAt(hit(VerdictReject, "b"), previous)
+	s.recordAt(hit(VerdictReject, "b"), previous)
+	s.recordAt(hit(VerdictReject, "c"), current)
+
+	resp := s.Report(&RuleSet{}, "hour", now, defaultStatsTopLimit)
+	want := []RuleHits{{RuleID: "a", Hits: 3, Previous: 1}, {RuleID: "c", Hits: 1}}
+	if !reflect.DeepEqual(resp.Rising, want) {
+		t.Errorf("rising %+v, want %+v", resp.Rising, want)
+	}
+	if len(resp.TopRules) != 3 || resp.TopRules[0].RuleID != "a" {
+		t.Errorf("top rules %+v", resp.TopRules)
+	}
+	if resp = s.Report(&RuleSet{}, "hour", now, 1); len(resp.TopRules) != 1 || len(resp.Rising) != 1 {
+		t.Errorf("limit 1: top rules %+v, rising %+v", resp.TopRules, resp.Rising)
+	}
+}
+
+func TestStatsDeadRules(t *testing.T) {
+	s, _ := NewHitStats("")
+	now := time.Now()
+	s.recordAt(hit(VerdictReject, "used"), now)
+	s.recordAt(hit(VerdictReject, "old"), now.Add(-2*time.Hour))
+	suppressed := hit(VerdictAllow, "suppressed")
+	suppressed.Violations[0].Suppressed = true
+	s.recordAt(suppressed, now)
+
+	rules := &RuleSet{Rules: []Rule{{ID: "used"}, {ID: "old"}, {ID: "suppressed"}, {ID: "off", Disabled: true}}}
+	resp := s.Report(rules, "hour", now, defaultStatsTopLimit)
+	if want := []string{"old", "suppressed"}; !reflect.DeepEqual(resp.DeadRules, want) {
+		t.Errorf("dead rules %v, want %v", resp.DeadRules, want)
+	}
+}
+
+func TestStatsSnapshotRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	s, err := NewHitStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.recordAt(hit(VerdictReject, "a"), now)
+	s.recordAt(hit(VerdictReview, "b"), now.Add(-25*time.Hour))
+	// Старше statsRetention: удаляется при сохранении
+	s.recordAt(hit(VerdictReject, "c"), now.Add(-statsRetention-time.Hour))
+	if err := s.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewHitStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.since.Equal(s.since) {
+		t.Errorf("since %v, want %v", restored.since, s.since)
+	}
+	for _, window := range []string{"hour", "day", "week"} {
+		got := restored.Report(&RuleSet{}, window, now, defaultStatsTopLimit)
+		want := s.Report(&RuleSet{}, window, now, defaultStatsTopLimit)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s after restore: %+v, want %+v", window, got, want)
+		}
+	}
+	if len(restored.buckets) != 2 {
+		t.Errorf("%d buckets after restore, want 2", len(restored.buckets))
+	}
+}
+
+func TestSkipStatsOnlyForBatch(t *testing.T) {
+	var err error
+	dictionary, err = NewDictionary("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validationPool = NewWorkerPool(1)
+	hitStats, _ = NewHitStats("")
+	defer func() { dictionary, validationPool, hitStats = nil, nil, nil }()
+
+	req := httptest.NewRequest("POST", "/validate", strings.NewReader(`{"text": "hello"}`))
+	req.Header.Set("X-Skip-Stats", "true")
+	handleValidate(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/validate/batch", strings.NewReader(`{"items": [{"id": 1, "text": "hello"}]}`))
+	req.Header.Set("X-Skip-Stats", "true")
+	handleValidateBatch(httptest.NewRecorder(), req)
+
+	if got := hitStats.Report(&RuleSet{}, "hour", time.Now(), defaultStatsTopLimit).Total; got != 1 {
+		t.Errorf("recorded %d checks, want 1: /validate ignores X-Skip-Stats, the batch endpoint honors it", got)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Облегченный стеммер: для русского - упрощенный алгоритм Snowball (без шага
+// словообразовательных суффиксов), для английского - отсечение типичных окончаний.
+// Работает с нормализованными словами: нижний регистр, ё заменена на е,
+// повторяющиеся буквы восстановлены до двойных (см. expandWord).
+
+var (
+	ruPerfectiveGerund1 = []string{"вшись", "вши", "в"}
+	ruPerfectiveGerund2 = []string{"ившись", "ывшись", "ивши", "ывши", "ив", "ыв"}
+	ruReflexive         = []string{"ся", "сь"}
+	ruAdjective         = []string{
+		"ими", "ыми", "его", "ого", "ему", "ому", "ее", "ие", "ые", "ое", "ей", "ий", "ый", "ой",
+		"ем", "им", "ым", "ом", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruVerb1       = []string{"ете", "йте", "ешь", "нно", "ла", "на", "ли", "ем", "ло", "но", "ет", "ют", "ны", "ть", "й", "л", "н"}
+	ruVerb2       = []string{
+		"ейте", "уйте", "ила", "ыла", "ена", "ите", "или", "ыли", "ило", "ыло", "ено", "ует", "уют", "ены", "ить", "ыть", "ишь",
+		"ей", "уй", "ил", "ыл", "им", "ым", "ен", "ят", "ит", "ыт", "ую", "ю",
+	}
+	ruNoun = []string{
+		"иями", "ями", "ами", "иях", "ией", "иям", "ием", "ев", "ов", "ие", "ье", "еи", "ии", "ей", "ой", "ий", "ям", "ем", "ам", "ом",
+		"ах", "ях", "ию", "ью", "ия", "ья", "а", "е", "и", "й", "о", "у", "ы", "ь", "ю", "я",
+	}
+	ruSuperlative = []string{"ейше", "ейш"}
+)
+
+var enSuffixes = []string{"ingly", "edly", "ings", "ness", "ing", "ies", "ied", "est", "ed", "es", "er", "ly", "s"}
+
+const ruVowels = "аеиоуыэюя"
+
+// stem возвращает основу слова
+func stem(word []rune) []rune {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return []rune(stemRussian(string(word)))
+		}
+	}
+	return []rune(stemEnglish(string(word)))
+}
+
+func stemRussian(word string) string {
+	runes := []rune(word)
+	rv := len(runes)
+	for i, r := range runes {
+		if strings.ContainsRune(ruVowels, r) {
+			rv = i + 1
+			break
+		}
+	}
+	prefix, region := string(runes[:rv]), string(runes[rv:])
+
+	// Шаг 1: деепричастие, иначе возвратная частица и затем прилагательное/глагол/существительное
+	if r, ok := trimSuffixAfter(region, ruPerfectiveGerund1, "ая"); ok {
+		region = r
+	} else if r, ok := trimSuffix(region, ruPerfectiveGerund2); ok {
+		region = r
+	} else {
+		if r, ok := trimSuffix(region, ruReflexive); ok {
+			region = r
+		}
+		if r, ok := trimSuffix(region, ruAdjective); ok {
+			region = r
+			if r, ok := trimSuffixAfter(region, ruParticiple1, "ая"); ok {
+				region = r
+			} else if r, ok := trimSuffix(region, ruParticiple2); ok {
+				region = r
+			}
+		} else if r, ok := trimSuffixAfter(region, ruVerb1, "ая"); ok {
+			region = r
+		} else if r, ok := trimSuffix(region, ruVerb2); ok {
+			region = r
+		} else if r, ok := trimSuffix(region, ruNoun); ok {
+			region = r
+		}
+	}
+
+	// Шаг 2: конечная и
+	region = strings.TrimSuffix(region, "и")
+
+	// Шаг 4: превосходная степень, нн и мягкий знак
+	if r, ok := trimSuffix(region, ruSuperlative); ok {
+		region = r
+	}
+	if strings.HasSuffix(region, "нн") {
+		region = strings.TrimSuffix(region, "н")
+	} else {
+		region = strings.TrimSuffix(region, "ь")
+	}
+
+	return prefix + region
+}
+
+// trimSuffix отрезает самое длинное подходящее окончание из списка (списки упорядочены по убыванию длины)
+func trimSuffix(word string, suffixes []string) (string, bool) {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix), true
+		}
+	}
+	return word, false
+}
+
+// trimSuffixAfter отрезает окончание, только если перед ним стоит одна из букв after
+func trimSuffixAfter(word string, suffixes []string, after string) (string, bool) {
+	for _, suffix := range suffixes {
+		if !strings.HasSuffix(word, suffix) {
+			continue
+		}
+		rest := []rune(strings.TrimSuffix(word, suffix))
+		if len(rest) > 0 && strings.ContainsRune(after, rest[len(rest)-1]) {
+			return string(rest), true
+		}
+	}
+	return word, false
+}
+
+func stemEnglish(word string) string {
+	word = strings.TrimSuffix(word, "'s")
+	for _, suffix := range enSuffixes {
+		if !strings.HasSuffix(word, suffix) || len(word)-len(suffix) < 3 {
+			continue
+		}
+		if suffix == "s" && strings.HasSuffix(word, "ss") {
+			return word
+		}
+		word = strings.TrimSuffix(word, suffix)
+		if suffix == "ies" || suffix == "ied" {
+			word += "y"
+		}
+		return word
+	}
+	return word
+}
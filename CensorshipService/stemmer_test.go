@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestStemModeWordForms(t *testing.T) {
+	tests := []struct {
+		term  string
+		text  string
+		match bool
+	}{
+		{"маска", "маска", true},
+		{"маска", "маски", true},
+		{"маской", "маской", true},
+		{"маска", "маской", true},
+		{"маска", "масок", true},
+		{"маска", "MACKA", true},
+		{"маска", "маскарад", false},
+		{"ложка", "ложек", true},
+		{"длинный", "длинного", true},
+		{"длинный", "длинная", true},
+		{"йцукен", "йцукены", true},
+		{"qwerty", "qwertys", true},
+		{"qwerty", "qwertyuiop", false},
+	}
+	for _, tt := range tests {
+		matched := matchedRules(t, []Rule{{ID: "rule", Term: tt.term, Mode: MatchStem}}, tt.text)
+		if matched["rule"] != tt.match {
+			t.Errorf("term %q, text %q: match = %v, want %v", tt.term, tt.text, matched["rule"], tt.match)
+		}
+	}
+}
+
+func TestStemRussianDoubleN(t *testing.T) {
+	tests := map[string]string{
+		"длинный":  "длин",
+		"длинного": "длин",
+		"маска":    "маск",
+		"маски":    "маск",
+		"маской":   "маск",
+	}
+	for word, want := range tests {
+		if got := stemRussian(word); got != want {
+			t.Errorf("stemRussian(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
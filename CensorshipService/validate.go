@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"unicode"
+)
+
+const defaultMaskChar = '*'
+
+// maskChar - символ, которым заменяются запрещенные слова в режиме mask
+var maskChar = defaultMaskChar
+
+// defaultPolicy - политика для запросов без поля policy
+var defaultPolicy = defaultPolicyName
+
+var (
+	ErrUnknownPolicy = errors.New("unknown policy")
+	ErrInvalidMode   = errors.New("mode must be reject or mask")
+)
+
+// Коды ошибок запроса проверки. По коду клиенты отличают ошибку запроса от вердикта reject,
+// который тоже приходит с кодом 400.
+const (
+	CodeUnknownPolicy = "unknown_policy"
+	CodeInvalidMode   = "invalid_mode"
+)
+
+// CheckError - тело ответа 400 на запрос с неизвестной политикой или неверным режимом
+type CheckError struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+// checkErrorCode возвращает код ошибки запроса проверки или пустую строку для других ошибок
+func checkErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownPolicy):
+		return CodeUnknownPolicy
+	case errors.Is(err, ErrInvalidMode):
+		return CodeInvalidMode
+	}
+	return ""
+}
+
+// writeCheckError отвечает 400 с кодом ошибки запроса проверки
+func writeCheckError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(CheckError{Code: checkErrorCode(err), Message: err.Error()})
+}
+
+// checkOptions проверяет режим и политику запроса и возвращает политику с ее именем.
+// Пустая политика - политика по умолчанию.
+func checkOptions(rules *RuleSet, mode, policyName string) (*Policy, string, error) {
+	if mode != "" && mode != ModeReject && mode != ModeMask {
+		return nil, "", ErrInvalidMode
+	}
+	if policyName == "" {
+		policyName = defaultPolicy
+	}
+	policy, ok := rules.policy(policyName)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnknownPolicy, policyName)
+	}
+	return policy, policyName, nil
+}
+
+// checkText проверяет текст по словарю, считает суммарный вес нарушений и применяет политику.
+// В режиме mask вердикт reject заменяется маскированием слов из правил с действием reject.
+func checkText(rules *RuleSet, req ValidateRequest) (ValidateResponse, error) {
+	policy, policyName, err := checkOptions(rules, req.Mode, req.Policy)
+	if err != nil {
+		return ValidateResponse{}, err
+	}
+
+	original := []rune(req.Text)
+	texts := normalize(req.Text)
+	matches := rules.Match(texts)
+	// Позиции рун и границы слов одинаковы во всех алфавитах
+	found := violations(rules, original, texts[scriptLatin], matches)
+	rules.suppress(original, texts, matches, found)
+
+	// Сообщение об ошибке называет только слова, которые не попали в исключения
+	active := matches[:0:0]
+	for i, m := range matches {
+		if !found[i].Suppressed {
+			active = append(active, m)
+		}
+	}
+
+	resp := ValidateResponse{
+		Valid:        true,
+		RulesVersion: rules.Version,
+		Policy:       policyName,
+		Violations:   found,
+	}
+	for _, checker := range rules.checkers {
+		resp.Violations = append(resp.Violations, checker.Check(original)...)
+	}
+	probability, classified := classifier.Check(original)
+	resp.ClassifierScore = probability
+	resp.Violations = append(resp.Violations, classified...)
+	sort.SliceStable(resp.Violations, func(i, j int) bool { return resp.Violations[i].Start < resp.Violations[j].Start })
+	resp.Score = totalScore(resp.Violations)
+	resp.Verdict = policy.verdict(resp.Violations)
+
+	if resp.Verdict == VerdictReject && req.Mode == ModeMask {
+		// Замаскированные слова больше не учитываются, но вердикт не может быть мягче mask.
+		// Если отклонить требуют нарушения, которые нельзя замаскировать, вердикт остается reject.
+		var rest []Violation
+		for _, v := range resp.Violations {
+			if !v.Suppressed && (v.Action != ActionReject || !v.maskable()) {
+				rest = append(rest, v)
+			}
+		}
+		if verdict := policy.verdict(rest); verdict != VerdictReject {
+			resp.Verdict = VerdictMask
+			if verdictRank[verdict] > verdictRank[VerdictMask] {
+				resp.Verdict = verdict
+			}
+			resp.MaskedSpans = maskedSpans(resp.Violations, true)
+			resp.MaskedText = maskText(original, resp.MaskedSpans)
+		}
+	} else if resp.Verdict == VerdictMask {
+		resp.MaskedSpans = maskedSpans(resp.Violations, false)
+		resp.MaskedText = maskText(original, resp.MaskedSpans)
+	}
+
+	switch resp.Verdict {
+	case VerdictReject:
+		resp.Valid = false
+		if rule, ok := rules.firstMatch(active, ActionReject); ok {
+			resp.Error = fmt.Sprintf("Comment contains forbidden word: %s", rule.Term)
+		} else if v, ok := firstMessage(resp.Violations, ActionReject); ok {
+			resp.Error = v.Message
+		} else {
+			resp.Error = fmt.Sprintf("Comment violates policy %s: score %g", policyName, resp.Score)
+		}
+	case VerdictReview:
+		if rule, ok := rules.firstMatch(active, ActionReview); ok {
+			resp.Reason = fmt.Sprintf("Comment contains word that needs review: %s", rule.Term)
+		} else if v, ok := firstMessage(resp.Violations, ActionReview); ok {
+			resp.Reason = v.Message
+		} else {
+			resp.Reason = fmt.Sprintf("Comment needs review under policy %s: score %g", policyName, resp.Score)
+		}
+	}
+	return resp, nil
+}
+
+// firstMessage возвращает первое нарушение эвристики с заданным действием
+func firstMessage(violations []Violation, action string) (Violation, bool) {
+	for _, v := range violations {
+		if v.Action == action && v.Message != "" && !v.Suppressed {
+			return v, true
+		}
+	}
+	return Violation{}, false
+}
+
+// maskable сообщает, можно ли скрыть нарушение маской. Эвристики и классификатор относятся ко всему тексту, а не к словам.
+func (v Violation) maskable() bool {
+	return v.Source != SourceHeuristic && v.Source != SourceClassifier
+}
+
+// totalScore - суммарный вес нарушений без подавленных исключениями
+func totalScore(violations []Violation) float64 {
+	score := 0.0
+	for _, v := range violations {
+		if !v.Suppressed {
+			score += v.Weight
+		}
+	}
+	return score
+}
+
+// violations переводит срабатывания правил в нарушения с позициями в исходном тексте
+func violations(rules *RuleSet, original []rune, text normalizedText, matches []RuleMatch) []Violation {
+	result := make([]Violation, 0, len(matches))
+	for _, m := range matches {
+		rule := rules.Rules[m.Rule]
+		start, end := originalSpan(original, text, m)
+		result = append(result, Violation{
+			RuleID:   rule.ID,
+			Source:   SourceDictionary,
+			Category: rule.Category,
+			Severity: rule.Severity,
+			Weight:   *rule.Weight,
+			Action:   rule.Action,
+			Match:    string(original[start:end]),
+			Start:    start,
+			End:      end,
+		})
+	}
+	return result
+}
+
+// originalSpan возвращает фрагмент исходного текста [start, end) в рунах, из которого получено совпадение
+func originalSpan(original []rune, text normalizedText, m RuleMatch) (int, int) {
+	end := text.Offsets[m.End-1] + 1
+	if m.End < len(text.Runes) && !text.Boundary[m.End] {
+		// Совпадение внутри слова: фрагмент продолжается до следующей буквы, включая отброшенные символы
+		end = text.Offsets[m.End]
+	} else {
+		// Схлопнутые при нормализации повторы последней буквы тоже входят во фрагмент
+		last := unicode.ToLower(original[end-1])
+		for end < len(original) && unicode.ToLower(original[end]) == last {
+			end++
+		}
+	}
+	return text.Offsets[m.Start], end
+}
+
+// maskedSpans возвращает фрагменты нарушений, которые нужно замаскировать: только с действием reject
+// или все, если onlyReject не задан. Нарушения эвристик и классификатора и подавленные исключениями не маскируются. Пересекающиеся фрагменты объединяются, правилом считается первое из них.
+func maskedSpans(violations []Violation, onlyReject bool) []MaskedSpan {
+	var merged []MaskedSpan
+	for _, v := range violations {
+		if v.Suppressed || !v.maskable() || onlyReject && v.Action != ActionReject {
+			continue
+		}
+		if n := len(merged); n > 0 && v.Start <= merged[n-1].End {
+			if v.End > merged[n-1].End {
+				merged[n-1].End = v.End
+			}
+			continue
+		}
+		merged = append(merged, MaskedSpan{Start: v.Start, End: v.End, RuleID: v.RuleID})
+	}
+	return merged
+}
+
+// maskText заменяет символы фрагментов маской, сохраняя длину текста. Пробелы внутри фрагмента не заменяются.
+func maskText(original []rune, spans []MaskedSpan) string {
+	runes := append([]rune{}, original...)
+	for _, span := range spans {
+		for i := span.Start; i < span.End; i++ {
+			if !unicode.IsSpace(runes[i]) {
+				runes[i] = maskChar
+			}
+		}
+	}
+	return string(runes)
+}
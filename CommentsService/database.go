@@ -2,16 +2,42 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+var (
+	ErrCommentNotFound    = errors.New("comment not found")
+	ErrParentNotFound     = errors.New("parent comment not found")
+	ErrParentNewsMismatch = errors.New("parent comment belongs to another news")
+	ErrThreadTooDeep      = errors.New("maximum thread depth exceeded")
+	ErrAlreadyModerated   = errors.New("comment is already moderated")
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+const commentColumns = "id, news_id, text, parent_comment_id, created_at, status, moderation_reason, moderated_at"
+
+// exportColumns - commentColumns с исходным текстом вместо замаскированного: для обучения нужен исходный текст
+const exportColumns = "id, news_id, COALESCE(original_text, text) AS text, parent_comment_id, created_at, status, moderation_reason, moderated_at"
+
+// keepOriginalText - часть UPDATE, которая при замене текста ($4) сохраняет исходный текст в original_text
+const keepOriginalText = "original_text = CASE WHEN $4::TEXT IS NULL THEN original_text ELSE COALESCE(original_text, text) END"
+
 type DB struct {
 	conn *sql.DB
+	// maxThreadDepth - максимальная глубина ветки, 0 - без ограничений.
+	// Корневой комментарий имеет глубину 1.
+	maxThreadDepth int
 }
 
-func NewDB(dsn string) (*DB, error) {
+func NewDB(dsn string, maxThreadDepth int) (*DB, error) {
 	conn, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -21,7 +47,7 @@ func NewDB(dsn string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db := &DB{conn: conn}
+	db := &DB{conn: conn, maxThreadDepth: maxThreadDepth}
 	if err := db.initSchema(); err != nil {
 		return nil, fmt.Errorf("failed to init schema: %w", err)
 	}
@@ -44,55 +70,398 @@ func (db *DB) initSchema() error {
 		return fmt.Errorf("failed to create comments table: %w", err)
 	}
 
+	// Ответ должен ссылаться на существующий комментарий к той же новости.
+	// NOT VALID - чтобы не падать на старых данных, созданных до появления ограничения.
+	// Удаление комментария с ответами запрещено (NO ACTION), чтобы не удалить ветку целиком;
+	// ограничение с ON DELETE CASCADE из прежней версии пересоздается.
+	query = `
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'comments_id_news_id_key') THEN
+			ALTER TABLE comments ADD CONSTRAINT comments_id_news_id_key UNIQUE (id, news_id);
+		END IF;
+		IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'comments_parent_fkey' AND confdeltype = 'c') THEN
+			ALTER TABLE comments DROP CONSTRAINT comments_parent_fkey;
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'comments_parent_fkey') THEN
+			ALTER TABLE comments ADD CONSTRAINT comments_parent_fkey
+				FOREIGN KEY (parent_comment_id, news_id) REFERENCES comments (id, news_id) NOT VALID;
+		END IF;
+	END
+	$$;
+	CREATE INDEX IF NOT EXISTS comments_parent_comment_id_idx ON comments (parent_comment_id);
+	CREATE INDEX IF NOT EXISTS comments_news_id_idx ON comments (news_id);
+	`
+	_, err = db.conn.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to create comments constraints: %w", err)
+	}
+
+	// Статус модерации: комментарии, созданные до его появления, считаются одобренными
+	query = `
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved'
+		CHECK (status IN ('pending', 'approved', 'rejected'));
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS moderation_reason TEXT;
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP;
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS auto_moderated BOOLEAN NOT NULL DEFAULT FALSE;
+	CREATE INDEX IF NOT EXISTS comments_status_idx ON comments (status, created_at);
+	`
+	_, err = db.conn.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to add moderation columns: %w", err)
+	}
+
+	// Исходный текст замаскированного комментария и политика, по которой он проверен.
+	// Повторная модерация проверяет исходный текст по той же политике.
+	query = `
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS original_text TEXT;
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS censorship_policy TEXT;
+	`
+	_, err = db.conn.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to add censorship columns: %w", err)
+	}
+
+	// Прогресс повторной модерации: последний проверенный id, чтобы прерванный проход можно было продолжить
+	query = `
+	CREATE TABLE IF NOT EXISTS remoderation_checkpoints (
+		name TEXT PRIMARY KEY,
+		last_id INTEGER NOT NULL,
+		rules_version TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	`
+	_, err = db.conn.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to create remoderation checkpoints table: %w", err)
+	}
+
 	return nil
 }
 
-func (db *DB) CreateComment(newsID int, text string, parentCommentID *int) (*Comment, error) {
-	var comment Comment
+// CreateComment сохраняет комментарий. originalText - исходный текст, если в text слова замаскированы,
+// policy - политика CensorshipService, по которой проверен текст; пустые значения не сохраняются.
+func (db *DB) CreateComment(newsID int, text string, parentCommentID *int, status, originalText, policy string) (*Comment, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	var parentID sql.NullInt64
 	if parentCommentID != nil {
+		if err := db.checkParent(tx, newsID, *parentCommentID); err != nil {
+			return nil, err
+		}
 		parentID = sql.NullInt64{Int64: int64(*parentCommentID), Valid: true}
 	}
 
-	err := db.conn.QueryRow(
-		"INSERT INTO comments (news_id, text, parent_comment_id, created_at) VALUES ($1, $2, $3, NOW()) RETURNING id, news_id, text, parent_comment_id, created_at",
-		newsID, text, parentID,
-	).Scan(&comment.ID, &comment.NewsID, &comment.Text, &parentID, &comment.CreatedAt)
+	comment, err := scanComment(tx.QueryRow(
+		"INSERT INTO comments (news_id, text, parent_comment_id, created_at, status, original_text, censorship_policy) VALUES ($1, $2, $3, NOW(), $4, NULLIF($5, ''), NULLIF($6, '')) RETURNING "+commentColumns,
+		newsID, text, parentID, status, originalText, policy,
+	))
 
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return nil, ErrParentNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create comment: %w", err)
 	}
 
-	if parentID.Valid {
-		parentIDInt := int(parentID.Int64)
-		comment.ParentCommentID = &parentIDInt
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return &comment, nil
+	return comment, nil
+}
+
+// checkParent проверяет, что родительский комментарий существует, относится к той же новости
+// и что новый ответ не превысит максимальную глубину ветки
+func (db *DB) checkParent(tx *sql.Tx, newsID, parentID int) error {
+	var parentNewsID int
+	err := tx.QueryRow("SELECT news_id FROM comments WHERE id = $1 FOR SHARE", parentID).Scan(&parentNewsID)
+	if err == sql.ErrNoRows {
+		return ErrParentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get parent comment: %w", err)
+	}
+
+	if parentNewsID != newsID {
+		return ErrParentNewsMismatch
+	}
+
+	if db.maxThreadDepth <= 0 {
+		return nil
+	}
+
+	var parentDepth int
+	err = tx.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_comment_id, 1 AS depth FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_comment_id, a.depth + 1
+			FROM comments c JOIN ancestors a ON c.id = a.parent_comment_id
+			WHERE a.depth <= $2
+		)
+		SELECT MAX(depth) FROM ancestors
+	`, parentID, db.maxThreadDepth).Scan(&parentDepth)
+	if err != nil {
+		return fmt.Errorf("failed to get thread depth: %w", err)
+	}
+
+	if parentDepth+1 > db.maxThreadDepth {
+		return ErrThreadTooDeep
+	}
+
+	return nil
 }
 
+// GetCommentsByNewsID возвращает одобренные комментарии к новости
 func (db *DB) GetCommentsByNewsID(newsID int) ([]Comment, error) {
 	rows, err := db.conn.Query(
-		"SELECT id, news_id, text, parent_comment_id, created_at FROM comments WHERE news_id = $1 ORDER BY created_at ASC",
-		newsID,
+		"SELECT "+commentColumns+" FROM comments WHERE news_id = $1 AND status = $2 ORDER BY created_at ASC",
+		newsID, StatusApproved,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query comments: %w", err)
 	}
 	defer rows.Close()
 
-	var comments []Comment
+	return scanComments(rows)
+}
+
+// GetCommentsByStatus возвращает очередь комментариев с заданным статусом, старые первыми
+func (db *DB) GetCommentsByStatus(status string, limit, offset int) ([]Comment, error) {
+	rows, err := db.conn.Query(
+		"SELECT "+commentColumns+" FROM comments WHERE status = $1 ORDER BY created_at ASC, id ASC LIMIT $2 OFFSET $3",
+		status, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanComments(rows)
+}
+
+func (db *DB) GetCommentByID(id int) (*Comment, error) {
+	comment, err := scanComment(db.conn.QueryRow("SELECT "+commentColumns+" FROM comments WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	return comment, nil
+}
+
+// ModerateComment меняет статус комментария и сохраняет причину решения модератора.
+// Непустой text заменяет текст комментария, исходный текст сохраняется в original_text.
+// Решение автоматической проверки (automatic) помечается auto_moderated и меняет только комментарий
+// в статусе pending, иначе возвращается ErrAlreadyModerated.
+func (db *DB) ModerateComment(id int, status, reason, text string, automatic bool) (*Comment, error) {
+	var moderationReason, newText sql.NullString
+	if reason != "" {
+		moderationReason = sql.NullString{String: reason, Valid: true}
+	}
+	if text != "" {
+		newText = sql.NullString{String: text, Valid: true}
+	}
+
+	var fromStatus sql.NullString
+	if automatic {
+		fromStatus = sql.NullString{String: StatusPending, Valid: true}
+	}
+
+	comment, err := scanComment(db.conn.QueryRow(
+		"UPDATE comments SET status = $2, moderation_reason = $3, moderated_at = NOW(), auto_moderated = $6, text = COALESCE($4, text), "+keepOriginalText+" WHERE id = $1 AND ($5::TEXT IS NULL OR status = $5) RETURNING "+commentColumns,
+		id, status, moderationReason, newText, fromStatus, automatic,
+	))
+	if err == sql.ErrNoRows && automatic {
+		if _, err := db.GetCommentByID(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyModerated
+	}
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to moderate comment: %w", err)
+	}
+	return comment, nil
+}
+
+// MaskPendingComment заменяет текст комментария в статусе pending, например на текст с замаскированными словами,
+// и сохраняет исходный текст в original_text. Статус не меняется: решение остается за модератором.
+// Если комментарий уже не в статусе pending, возвращает ErrAlreadyModerated.
+func (db *DB) MaskPendingComment(id int, text string) (*Comment, error) {
+	comment, err := scanComment(db.conn.QueryRow(
+		"UPDATE comments SET text = $2, original_text = COALESCE(original_text, text) WHERE id = $1 AND status = $3 RETURNING "+commentColumns,
+		id, text, StatusPending,
+	))
+	if err == sql.ErrNoRows {
+		if _, err := db.GetCommentByID(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyModerated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to mask comment: %w", err)
+	}
+	return comment, nil
+}
+
+// GetApprovedCommentsAfter возвращает до limit одобренных комментариев с id больше afterID по возрастанию id
+// вместе с исходным текстом и политикой проверки. Заполняются только поля, нужные повторной модерации.
+func (db *DB) GetApprovedCommentsAfter(afterID, limit int) ([]remoderationComment, error) {
+	rows, err := db.conn.Query(
+		"SELECT id, news_id, text, COALESCE(original_text, text), COALESCE(censorship_policy, '') FROM comments WHERE id > $1 AND status = $2 ORDER BY id ASC LIMIT $3",
+		afterID, StatusApproved, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []remoderationComment
 	for rows.Next() {
-		var comment Comment
-		var parentID sql.NullInt64
-		if err := rows.Scan(&comment.ID, &comment.NewsID, &comment.Text, &parentID, &comment.CreatedAt); err != nil {
+		c := remoderationComment{Comment: Comment{Status: StatusApproved}}
+		if err := rows.Scan(&c.ID, &c.NewsID, &c.Text, &c.OriginalText, &c.Policy); err != nil {
 			return nil, fmt.Errorf("failed to scan comment: %w", err)
 		}
-		if parentID.Valid {
-			parentIDInt := int(parentID.Int64)
-			comment.ParentCommentID = &parentIDInt
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read comments: %w", err)
+	}
+	return comments, nil
+}
+
+// RemoderateComment меняет статус одобренного комментария. Непустой text заменяет текст,
+// исходный текст при этом сохраняется в original_text. Если комментарий уже не одобрен
+// (например, его успел отклонить модератор), возвращает ErrCommentNotFound.
+func (db *DB) RemoderateComment(id int, status, reason, text string) error {
+	var newText sql.NullString
+	if text != "" {
+		newText = sql.NullString{String: text, Valid: true}
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE comments SET status = $2, moderation_reason = $3, moderated_at = NOW(), auto_moderated = TRUE, text = COALESCE($4, text), "+keepOriginalText+" WHERE id = $1 AND status = $5",
+		id, status, reason, newText, StatusApproved,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remoderate comment: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// GetRemoderationCheckpoint возвращает последний проверенный id прохода name, 0 если проход не запускался
+func (db *DB) GetRemoderationCheckpoint(name string) (int, error) {
+	var lastID int
+	err := db.conn.QueryRow("SELECT last_id FROM remoderation_checkpoints WHERE name = $1", name).Scan(&lastID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get remoderation checkpoint: %w", err)
+	}
+	return lastID, nil
+}
+
+func (db *DB) SaveRemoderationCheckpoint(name string, lastID int, rulesVersion string) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO remoderation_checkpoints (name, last_id, rules_version, updated_at) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (name) DO UPDATE SET last_id = EXCLUDED.last_id, rules_version = EXCLUDED.rules_version, updated_at = NOW()
+	`, name, lastID, rulesVersion)
+	if err != nil {
+		return fmt.Errorf("failed to save remoderation checkpoint: %w", err)
+	}
+	return nil
+}
+
+// ExportModeratedComments передает в fn одобренные и отклоненные комментарии с id больше afterID по возрастанию id.
+// Text замаскированных комментариев - исходный текст из original_text.
+// moderatedOnly оставляет только комментарии, по которым решение принял модератор: без одобренных при создании
+// и без решений автоматической проверки и повторной модерации (auto_moderated). last > 0 оставляет только last последних комментариев.
+func (db *DB) ExportModeratedComments(afterID int, moderatedOnly bool, last int, fn func(Comment) error) error {
+	query := "SELECT " + exportColumns + " FROM comments WHERE id > $1 AND status IN ($2, $3)"
+	if moderatedOnly {
+		query += " AND moderated_at IS NOT NULL AND NOT auto_moderated"
+	}
+	args := []interface{}{afterID, StatusApproved, StatusRejected}
+	if last > 0 {
+		query = "SELECT " + commentColumns + " FROM (" + query + " ORDER BY id DESC LIMIT $4) AS recent"
+		args = append(args, last)
+	}
+	query += " ORDER BY id ASC"
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan comment: %w", err)
 		}
-		comments = append(comments, comment)
+		if err := fn(*comment); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read comments: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanComment читает строку с колонками commentColumns
+func scanComment(row rowScanner) (*Comment, error) {
+	var comment Comment
+	var parentID sql.NullInt64
+	var reason sql.NullString
+	var moderatedAt sql.NullTime
+	err := row.Scan(&comment.ID, &comment.NewsID, &comment.Text, &parentID, &comment.CreatedAt,
+		&comment.Status, &reason, &moderatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		parentIDInt := int(parentID.Int64)
+		comment.ParentCommentID = &parentIDInt
+	}
+	comment.ModerationReason = reason.String
+	if moderatedAt.Valid {
+		comment.ModeratedAt = &moderatedAt.Time
+	}
+
+	return &comment, nil
+}
+
+func scanComments(rows *sql.Rows) ([]Comment, error) {
+	var comments []Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, *comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read comments: %w", err)
 	}
 
 	return comments, nil
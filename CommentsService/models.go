@@ -3,16 +3,48 @@ package main
 import "time"
 
 type Comment struct {
-	ID              int       `json:"id"`
-	NewsID          int       `json:"news_id"`
-	Text            string    `json:"text"`
-	ParentCommentID *int      `json:"parent_comment_id,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID               int        `json:"id"`
+	NewsID           int        `json:"news_id"`
+	Text             string     `json:"text"`
+	ParentCommentID  *int       `json:"parent_comment_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Status           string     `json:"status"`
+	ModerationReason string     `json:"moderation_reason,omitempty"`
+	ModeratedAt      *time.Time `json:"moderated_at,omitempty"`
 }
 
 type CreateCommentRequest struct {
 	NewsID          int    `json:"news_id"`
 	Text            string `json:"text"`
 	ParentCommentID *int   `json:"parent_comment_id,omitempty"`
+	// Status - начальный статус модерации: approved (по умолчанию) или pending
+	Status string `json:"status,omitempty"`
+	// OriginalText - исходный текст, если в Text слова замаскированы; нужен для повторной модерации
+	OriginalText string `json:"original_text,omitempty"`
+	// Policy - политика CensorshipService, по которой проверен текст; ее же использует повторная модерация
+	Policy string `json:"policy,omitempty"`
 }
 
+type ModerationRequest struct {
+	Reason string `json:"reason"`
+	// Text - новый текст комментария, например с замаскированными словами; пустой - текст не меняется
+	Text string `json:"text,omitempty"`
+	// Automatic - решение принято автоматической проверкой: применяется только к комментарию
+	// в статусе pending, чтобы не перезаписать решение модератора
+	Automatic bool `json:"automatic,omitempty"`
+}
+
+// CommentNode - комментарий в дереве обсуждения
+type CommentNode struct {
+	Comment
+	Replies     []*CommentNode `json:"replies"`
+	Score       int            `json:"score"`
+	MoreReplies int            `json:"more_replies,omitempty"`
+	Orphan      bool           `json:"orphan,omitempty"`
+}
+
+type CommentTreeResponse struct {
+	Comments []*CommentNode `json:"comments"`
+	Total    int            `json:"total"`
+	Orphans  int            `json:"orphans"`
+}
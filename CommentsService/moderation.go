@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultQueueLimit = 50
+	maxQueueLimit     = 500
+)
+
+// adminAuth пропускает запрос только с заголовком Authorization: Bearer <token>.
+// С пустым токеном все запросы отклоняются.
+func adminAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, APIError{Code: "unauthorized", Message: "invalid admin token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleModerationQueue - GET /admin/comments?status=pending&limit=N&offset=N
+func handleModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status == "" {
+		status = StatusPending
+	}
+	if !isValidStatus(status) {
+		http.Error(w, "status must be pending, approved or rejected", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultQueueLimit
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxQueueLimit {
+			http.Error(w, fmt.Sprintf("limit must be an integer between 1 and %d", maxQueueLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	offset := 0
+	if o := query.Get("offset"); o != "" {
+		n, err := strconv.Atoi(o)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	comments, err := db.GetCommentsByStatus(status, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get comments: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if comments == nil {
+		comments = []Comment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+// handleModerateComment - POST /admin/comments/{id}/approve и POST /admin/comments/{id}/reject
+func handleModerateComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var id int
+	var action string
+	if _, err := fmt.Sscanf(r.URL.Path, "/admin/comments/%d/%s", &id, &action); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	switch action {
+	case "approve":
+		status = StatusApproved
+	case "reject":
+		status = StatusRejected
+	case "mask":
+		status = StatusPending
+	default:
+		http.Error(w, "Invalid path", http.StatusNotFound)
+		return
+	}
+
+	var req ModerationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if status == StatusRejected && strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "Reason is required", http.StatusBadRequest)
+		return
+	}
+	if status == StatusPending && req.Text == "" {
+		http.Error(w, "Text is required", http.StatusBadRequest)
+		return
+	}
+
+	var comment *Comment
+	var err error
+	if status == StatusPending {
+		// mask заменяет текст комментария в очереди модерации, не принимая решения
+		comment, err = db.MaskPendingComment(id, req.Text)
+	} else {
+		comment, err = db.ModerateComment(id, status, req.Reason, req.Text, req.Automatic)
+	}
+	if errors.Is(err, ErrCommentNotFound) {
+		writeJSONError(w, http.StatusNotFound, APIError{Code: "comment_not_found", Message: err.Error()})
+		return
+	}
+	if errors.Is(err, ErrAlreadyModerated) {
+		writeJSONError(w, http.StatusConflict, APIError{Code: "already_moderated", Message: err.Error()})
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to moderate comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+func isValidStatus(status string) bool {
+	return status == StatusPending || status == StatusApproved || status == StatusRejected
+}
+
+// TrainingExample - строка выгрузки для обучения классификатора CensorshipService
+type TrainingExample struct {
+	ID    int    `json:"id"`
+	Text  string `json:"text"`
+	Label string `json:"label"`
+}
+
+// handleExportComments - GET /admin/comments/export?after_id=0&moderated_only=true&last=N
+// Выгружает решения модерации в JSONL: одобренные комментарии с меткой clean, отклоненные - abusive.
+func handleExportComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	afterID := 0
+	if a := query.Get("after_id"); a != "" {
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 {
+			http.Error(w, "after_id must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		afterID = n
+	}
+	moderatedOnly := query.Get("moderated_only") == "true"
+
+	last := 0
+	if l := query.Get("last"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			http.Error(w, "last must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		last = n
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	err := db.ExportModeratedComments(afterID, moderatedOnly, last, func(c Comment) error {
+		label := "clean"
+		if c.Status == StatusRejected {
+			label = "abusive"
+		}
+		return encoder.Encode(TrainingExample{ID: c.ID, Text: c.Text, Label: label})
+	})
+	if err != nil {
+		// Заголовки уже отправлены, поэтому ошибку можно только записать в лог
+		slog.Error("Failed to export comments", "error", err, "request_id", r.Header.Get("X-Request-ID"))
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultCensorshipURL     = "http://localhost:8083"
+	defaultRemoderationBatch = 100
+	remoderationCheckpoint   = "default"
+	maxReportChanges         = 1000
+)
+
+// RemoderationOptions - параметры прохода повторной модерации
+type RemoderationOptions struct {
+	CensorshipURL string
+	BatchSize     int
+	// Policy - политика для комментариев, сохраненных без политики; пустая - политика по умолчанию CensorshipService
+	Policy string
+	// DryRun - только отчет: статусы и контрольная точка не меняются
+	DryRun bool
+	// FromStart - начать с первого комментария, не продолжая прерванный проход
+	FromStart bool
+}
+
+// RemoderationChange - комментарий, который по новым правилам нужно скрыть или проверить
+type RemoderationChange struct {
+	CommentID int    `json:"comment_id"`
+	NewsID    int    `json:"news_id"`
+	Verdict   string `json:"verdict"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	// Applied - статус изменен; false в режиме dry-run или если комментарий уже изменил модератор
+	Applied bool `json:"applied"`
+}
+
+// RemoderationReport - результат прохода
+type RemoderationReport struct {
+	DryRun       bool                 `json:"dry_run"`
+	Running      bool                 `json:"running"`
+	StartedAt    time.Time            `json:"started_at"`
+	FinishedAt   *time.Time           `json:"finished_at,omitempty"`
+	ResumedFrom  int                  `json:"resumed_from"`
+	LastID       int                  `json:"last_id"`
+	RulesVersion string               `json:"rules_version,omitempty"`
+	Scanned      int                  `json:"scanned"`
+	Rejected     int                  `json:"rejected"`
+	Flagged      int                  `json:"flagged"`
+	Masked       int                  `json:"masked"`
+	Changes      []RemoderationChange `json:"changes"`
+	// ChangesTruncated - в отчете только первые maxReportChanges изменений
+	ChangesTruncated bool   `json:"changes_truncated,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// remoderationComment - одобренный комментарий с исходным текстом и политикой, по которой он проверялся
+type remoderationComment struct {
+	Comment
+	// OriginalText - текст до маскирования; совпадает с Text, если текст не маскировался
+	OriginalText string
+	Policy       string
+}
+
+type batchItem struct {
+	ID     int    `json:"id"`
+	Text   string `json:"text"`
+	Policy string `json:"policy,omitempty"`
+}
+
+type batchResult struct {
+	ID          int    `json:"id"`
+	Verdict     string `json:"verdict"`
+	Error       string `json:"error"`
+	Reason      string `json:"reason"`
+	MaskedText  string `json:"masked_text"`
+	Failure     string `json:"failure"`
+	FailureCode string `json:"failure_code"`
+}
+
+type batchResponse struct {
+	RulesVersion string        `json:"rules_version"`
+	Results      []batchResult `json:"results"`
+}
+
+// remoderationStore - операции с базой, которые нужны проходу повторной модерации; реализуется *DB
+type remoderationStore interface {
+	GetRemoderationCheckpoint(name string) (int, error)
+	SaveRemoderationCheckpoint(name string, lastID int, rulesVersion string) error
+	GetApprovedCommentsAfter(afterID, limit int) ([]remoderationComment, error)
+	RemoderateComment(id int, status, reason, text string) error
+}
+
+var censorshipHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// validateBatch отправляет исходные тексты в CensorshipService /validate/batch, каждый - по политике комментария.
+// Проверки не учитываются в статистике срабатываний (X-Skip-Stats). Если пачка больше, чем принимает
+// CensorshipService (413), она делится пополам.
+func validateBatch(ctx context.Context, opts RemoderationOptions, comments []remoderationComment) (*batchResponse, error) {
+	items := make([]batchItem, len(comments))
+	for i, c := range comments {
+		items[i] = batchItem{ID: c.ID, Text: c.OriginalText, Policy: c.Policy}
+	}
+	body, err := json.Marshal(map[string]interface{}{"items": items, "policy": opts.Policy})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.CensorshipURL+"/validate/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "remoderation-"+generateRequestID())
+	req.Header.Set("X-Skip-Stats", "true")
+
+	resp, err := censorshipHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate batch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusRequestEntityTooLarge && len(comments) > 1 {
+		resp.Body.Close()
+		return splitBatch(ctx, opts, comments)
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to validate batch: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode batch response: %w", err)
+	}
+	if len(result.Results) != len(comments) {
+		return nil, fmt.Errorf("censorship service returned %d results for %d comments", len(result.Results), len(comments))
+	}
+	return &result, nil
+}
+
+// splitBatch проверяет две половины пачки отдельными запросами и объединяет результаты
+func splitBatch(ctx context.Context, opts RemoderationOptions, comments []remoderationComment) (*batchResponse, error) {
+	half := len(comments) / 2
+	first, err := validateBatch(ctx, opts, comments[:half])
+	if err != nil {
+		return nil, err
+	}
+	second, err := validateBatch(ctx, opts, comments[half:])
+	if err != nil {
+		return nil, err
+	}
+	if first.RulesVersion != second.RulesVersion {
+		return nil, fmt.Errorf("censorship rules changed during the batch: %s, %s", first.RulesVersion, second.RulesVersion)
+	}
+	first.Results = append(first.Results, second.Results...)
+	return first, nil
+}
+
+// retryUnknownPolicies заново проверяет по политике opts.Policy комментарии, сохраненная политика которых
+// больше не существует в CensorshipService (например, ее удалили из словаря)
+func retryUnknownPolicies(ctx context.Context, opts RemoderationOptions, comments []remoderationComment, result *batchResponse) error {
+	var retry []remoderationComment
+	var indexes []int
+	for i, res := range result.Results {
+		if res.FailureCode == "unknown_policy" && comments[i].Policy != "" {
+			slog.Warn("Unknown censorship policy, re-moderating with the default policy",
+				"comment_id", comments[i].ID, "policy", comments[i].Policy)
+			c := comments[i]
+			c.Policy = ""
+			retry = append(retry, c)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(retry) == 0 {
+		return nil
+	}
+
+	retried, err := validateBatch(ctx, opts, retry)
+	if err != nil {
+		return err
+	}
+	for j, i := range indexes {
+		result.Results[i] = retried.Results[j]
+	}
+	return nil
+}
+
+// runRemoderation проверяет одобренные комментарии по текущим правилам CensorshipService.
+// Проверяется исходный текст комментария по политике, с которой он был создан.
+// Вердикт reject скрывает комментарий (rejected), review возвращает его в очередь модерации (pending),
+// mask заменяет текст замаскированным, исходный текст при этом сохраняется. После каждой пачки сохраняется контрольная точка,
+// поэтому прерванный проход продолжается с места остановки. progress вызывается после каждой пачки.
+func runRemoderation(ctx context.Context, store remoderationStore, opts RemoderationOptions, progress func(RemoderationReport)) (RemoderationReport, error) {
+	report := RemoderationReport{DryRun: opts.DryRun, Running: true, StartedAt: time.Now().UTC(), Changes: []RemoderationChange{}}
+
+	if !opts.FromStart {
+		lastID, err := store.GetRemoderationCheckpoint(remoderationCheckpoint)
+		if err != nil {
+			return report, err
+		}
+		report.ResumedFrom = lastID
+	}
+	report.LastID = report.ResumedFrom
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		comments, err := store.GetApprovedCommentsAfter(report.LastID, opts.BatchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(comments) == 0 {
+			break
+		}
+
+		result, err := validateBatch(ctx, opts, comments)
+		if err != nil {
+			return report, err
+		}
+		if err := retryUnknownPolicies(ctx, opts, comments, result); err != nil {
+			return report, err
+		}
+		report.RulesVersion = result.RulesVersion
+
+		for i, comment := range comments {
+			res := result.Results[i]
+			if res.Failure != "" {
+				return report, fmt.Errorf("failed to validate comment %d: %s", comment.ID, res.Failure)
+			}
+			report.Scanned++
+			if change, ok := applyVerdict(store, comment, res, opts.DryRun); ok {
+				report.addChange(change)
+			}
+		}
+
+		report.LastID = comments[len(comments)-1].ID
+		if !opts.DryRun {
+			if err := store.SaveRemoderationCheckpoint(remoderationCheckpoint, report.LastID, report.RulesVersion); err != nil {
+				return report, err
+			}
+		}
+		if progress != nil {
+			progress(report)
+		}
+	}
+
+	// Проход завершен: следующий начнется сначала
+	if !opts.DryRun {
+		if err := store.SaveRemoderationCheckpoint(remoderationCheckpoint, 0, report.RulesVersion); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// applyVerdict применяет вердикт к комментарию; false, если комментарий остается как есть
+func applyVerdict(store remoderationStore, comment remoderationComment, res batchResult, dryRun bool) (RemoderationChange, bool) {
+	change := RemoderationChange{CommentID: comment.ID, NewsID: comment.NewsID, Verdict: res.Verdict}
+	text := ""
+	switch res.Verdict {
+	case "reject":
+		change.Status = StatusRejected
+		change.Reason = "Re-moderation: " + res.Error
+	case "review":
+		change.Status = StatusPending
+		change.Reason = "Re-moderation: " + res.Reason
+	case "mask":
+		if res.MaskedText == "" || res.MaskedText == comment.Text {
+			return change, false
+		}
+		change.Status = StatusApproved
+		change.Reason = "Re-moderation: text masked"
+		text = res.MaskedText
+	default:
+		return change, false
+	}
+
+	if dryRun {
+		return change, true
+	}
+
+	err := store.RemoderateComment(comment.ID, change.Status, change.Reason, text)
+	switch {
+	case errors.Is(err, ErrCommentNotFound):
+		// Статус уже изменил модератор
+	case err != nil:
+		slog.Error("Failed to remoderate comment", "comment_id", comment.ID, "error", err)
+	default:
+		change.Applied = true
+	}
+	return change, true
+}
+
+func (r *RemoderationReport) addChange(change RemoderationChange) {
+	switch change.Verdict {
+	case "reject":
+		r.Rejected++
+	case "review":
+		r.Flagged++
+	case "mask":
+		r.Masked++
+	}
+	if len(r.Changes) < maxReportChanges {
+		r.Changes = append(r.Changes, change)
+	} else {
+		r.ChangesTruncated = true
+	}
+}
+
+// remoderationJob - фоновый проход, запущенный через административный API. Одновременно выполняется один проход.
+type remoderationJob struct {
+	opts RemoderationOptions
+	// ctx отменяется при остановке сервера: проход прерывается после текущей пачки
+	// и продолжится с контрольной точки при следующем запуске
+	ctx context.Context
+
+	mu      sync.Mutex
+	running bool
+	report  *RemoderationReport
+}
+
+var remoderation *remoderationJob
+
+func (j *remoderationJob) start(opts RemoderationOptions) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running {
+		return false
+	}
+	j.running = true
+	j.report = &RemoderationReport{DryRun: opts.DryRun, Running: true, StartedAt: time.Now().UTC()}
+
+	go func() {
+		report, err := runRemoderation(j.ctx, db, opts, func(r RemoderationReport) {
+			j.mu.Lock()
+			j.report = &r
+			j.mu.Unlock()
+		})
+		finished := time.Now().UTC()
+		report.FinishedAt = &finished
+		report.Running = false
+		if err != nil {
+			report.Error = err.Error()
+			slog.Error("Re-moderation failed", "last_id", report.LastID, "error", err)
+		} else {
+			slog.Info("Re-moderation finished", "scanned", report.Scanned, "rejected", report.Rejected,
+				"flagged", report.Flagged, "masked", report.Masked, "dry_run", report.DryRun)
+		}
+
+		j.mu.Lock()
+		j.running = false
+		j.report = &report
+		j.mu.Unlock()
+	}()
+	return true
+}
+
+// handleRemoderation - POST /admin/remoderate?dry_run=true&from_start=true&policy=... запускает проход,
+// GET /admin/remoderate возвращает отчет текущего или последнего прохода
+func handleRemoderation(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		remoderation.mu.Lock()
+		report := remoderation.report
+		remoderation.mu.Unlock()
+		if report == nil {
+			http.Error(w, "Re-moderation has not been started", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case http.MethodPost:
+		query := r.URL.Query()
+		opts := remoderation.opts
+		opts.DryRun = query.Get("dry_run") == "true"
+		opts.FromStart = query.Get("from_start") == "true"
+		if policy := query.Get("policy"); policy != "" {
+			opts.Policy = policy
+		}
+		if !remoderation.start(opts) {
+			writeJSONError(w, http.StatusConflict, APIError{Code: "remoderation_running", Message: "re-moderation is already running"})
+			return
+		}
+		w.Header().Set("Location", "/admin/remoderate")
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// runRemoderateCommand - подкоманда "remoderate": проход в консоли, отчет в stdout в формате JSON.
+// SIGINT/SIGTERM останавливают проход после текущей пачки, следующий запуск продолжит с контрольной точки.
+func runRemoderateCommand(args []string) error {
+	fs := flag.NewFlagSet("remoderate", flag.ExitOnError)
+	dsn := fs.String("dsn", defaultDSN, "Database connection string")
+	censorshipURL := fs.String("censorship-url", defaultCensorshipURL, "Censorship service URL")
+	batchSize := fs.Int("batch", defaultRemoderationBatch, "Comments per request to the censorship service")
+	policy := fs.String("policy", "", "Censorship policy for comments stored without one, empty means the service default")
+	dryRun := fs.Bool("dry-run", false, "Only report comments that would change")
+	fromStart := fs.Bool("from-start", false, "Ignore the checkpoint and scan all comments")
+	fs.Parse(args)
+
+	if *batchSize < 1 {
+		return fmt.Errorf("-batch must be positive")
+	}
+
+	var err error
+	db, err = NewDB(*dsn, 0)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	opts := RemoderationOptions{
+		CensorshipURL: *censorshipURL,
+		BatchSize:     *batchSize,
+		Policy:        *policy,
+		DryRun:        *dryRun,
+		FromStart:     *fromStart,
+	}
+	report, runErr := runRemoderation(ctx, db, opts, func(r RemoderationReport) {
+		slog.Info("Re-moderation progress", "last_id", r.LastID, "scanned", r.Scanned,
+			"rejected", r.Rejected, "flagged", r.Flagged, "masked", r.Masked)
+	})
+	finished := time.Now().UTC()
+	report.FinishedAt = &finished
+	report.Running = false
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	return runErr
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryStore - одобренные комментарии и контрольная точка в памяти
+type memoryStore struct {
+	comments   []remoderationComment
+	checkpoint int
+	// saved - все сохраненные контрольные точки по порядку
+	saved []int
+	// moderated - id комментариев, статус которых успел изменить модератор
+	moderated map[int]bool
+	// applied - id комментариев, к которым применен вердикт
+	applied []int
+}
+
+func (s *memoryStore) GetRemoderationCheckpoint(name string) (int, error) {
+	return s.checkpoint, nil
+}
+
+func (s *memoryStore) SaveRemoderationCheckpoint(name string, lastID int, rulesVersion string) error {
+	s.checkpoint = lastID
+	s.saved = append(s.saved, lastID)
+	return nil
+}
+
+func (s *memoryStore) GetApprovedCommentsAfter(afterID, limit int) ([]remoderationComment, error) {
+	var result []remoderationComment
+	for _, c := range s.comments {
+		if c.ID > afterID && len(result) < limit {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryStore) RemoderateComment(id int, status, reason, text string) error {
+	if s.moderated[id] {
+		return ErrCommentNotFound
+	}
+	s.applied = append(s.applied, id)
+	return nil
+}
+
+// newMemoryStore создает одобренные комментарии с текстами texts и id с 1
+func newMemoryStore(texts ...string) *memoryStore {
+	s := &memoryStore{moderated: map[int]bool{}}
+	for i, text := range texts {
+		c := remoderationComment{Comment: Comment{ID: i + 1, NewsID: 1, Text: text, Status: StatusApproved}, OriginalText: text}
+		s.comments = append(s.comments, c)
+	}
+	return s
+}
+
+// censorshipStub отвечает на /validate/batch: текст со словом bad отклоняется, со словом spam уходит на проверку.
+// Пачки больше maxItems получают 413. requests - размеры принятых пачек.
+type censorshipStub struct {
+	mu       sync.Mutex
+	maxItems int
+	requests []int
+}
+
+func (c *censorshipStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Items []batchItem `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if c.maxItems > 0 && len(req.Items) > c.maxItems {
+		http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, len(req.Items))
+	c.mu.Unlock()
+
+	resp := batchResponse{RulesVersion: "v1"}
+	for _, item := range req.Items {
+		res := batchResult{ID: item.ID, Verdict: "allow"}
+		switch {
+		case strings.Contains(item.Text, "bad"):
+			res.Verdict = "reject"
+			res.Error = "Comment contains forbidden word: bad"
+		case strings.Contains(item.Text, "spam"):
+			res.Verdict = "review"
+			res.Reason = "Comment contains word that needs review: spam"
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func newCensorshipStub(t *testing.T, maxItems int) (*censorshipStub, RemoderationOptions) {
+	stub := &censorshipStub{maxItems: maxItems}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, RemoderationOptions{CensorshipURL: server.URL, BatchSize: 2}
+}
+
+func changedIDs(report RemoderationReport) []int {
+	var ids []int
+	for _, c := range report.Changes {
+		ids = append(ids, c.CommentID)
+	}
+	return ids
+}
+
+func TestRemoderationResumesFromCheckpoint(t *testing.T) {
+	store := newMemoryStore("bad", "ok", "ok", "spam", "bad")
+	store.checkpoint = 2
+	_, opts := newCensorshipStub(t, 0)
+
+	report, err := runRemoderation(context.Background(), store, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ResumedFrom != 2 || report.Scanned != 3 || report.LastID != 5 {
+		t.Errorf("resumed from %d, scanned %d, last id %d; want 2, 3, 5", report.ResumedFrom, report.Scanned, report.LastID)
+	}
+	// Комментарий 1 до контрольной точки не проверяется
+	if got, want := changedIDs(report), []int{4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed %v, want %v", got, want)
+	}
+	// Контрольная точка после каждой пачки, в конце прохода - сброс
+	if want := []int{4, 5, 0}; !reflect.DeepEqual(store.saved, want) {
+		t.Errorf("checkpoints %v, want %v", store.saved, want)
+	}
+
+	opts.FromStart = true
+	store.checkpoint = 4
+	report, err = runRemoderation(context.Background(), store, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ResumedFrom != 0 || report.Scanned != 5 {
+		t.Errorf("from start: resumed from %d, scanned %d; want 0, 5", report.ResumedFrom, report.Scanned)
+	}
+}
+
+func TestRemoderationSplitsTooLargeBatch(t *testing.T) {
+	store := newMemoryStore("ok", "bad", "ok", "ok", "spam")
+	stub, opts := newCensorshipStub(t, 1)
+	opts.BatchSize = 5
+
+	report, err := runRemoderation(context.Background(), store, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scanned != 5 || report.Rejected != 1 || report.Flagged != 1 {
+		t.Errorf("scanned %d, rejected %d, flagged %d; want 5, 1, 1", report.Scanned, report.Rejected, report.Flagged)
+	}
+	if got, want := changedIDs(report), []int{2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed %v, want %v", got, want)
+	}
+	for _, n := range stub.requests {
+		if n > 1 {
+			t.Errorf("accepted batch of %d items, limit is 1", n)
+		}
+	}
+	if len(stub.requests) != 5 {
+		t.Errorf("%d accepted requests, want 5", len(stub.requests))
+	}
+}
+
+func TestRemoderationSkipsModeratedComments(t *testing.T) {
+	store := newMemoryStore("bad", "bad", "spam")
+	store.moderated[2] = true
+	_, opts := newCensorshipStub(t, 0)
+
+	report, err := runRemoderation(context.Background(), store, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(store.applied, want) {
+		t.Errorf("applied %v, want %v", store.applied, want)
+	}
+	for _, c := range report.Changes {
+		if c.Applied != (c.CommentID != 2) {
+			t.Errorf("comment %d: applied %v", c.CommentID, c.Applied)
+		}
+	}
+}
+
+func TestRemoderationDryRun(t *testing.T) {
+	store := newMemoryStore("bad", "ok", "spam")
+	store.checkpoint = 1
+	_, opts := newCensorshipStub(t, 0)
+	opts.DryRun = true
+
+	report, err := runRemoderation(context.Background(), store, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.Flagged != 1 || len(report.Changes) != 1 {
+		t.Fatalf("dry run %v, flagged %d, %d changes; want true, 1, 1", report.DryRun, report.Flagged, len(report.Changes))
+	}
+	if report.Changes[0].Applied {
+		t.Error("dry run change is applied")
+	}
+	if len(store.applied) != 0 || len(store.saved) != 0 || store.checkpoint != 1 {
+		t.Errorf("dry run changed the store: applied %v, checkpoints %v", store.applied, store.saved)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+const (
+	TreeSortTime  = "time"
+	TreeSortScore = "score"
+)
+
+// TreeOptions - параметры построения дерева комментариев
+type TreeOptions struct {
+	// MaxDepth ограничивает глубину дерева, 0 - без ограничений.
+	// Корневые комментарии находятся на глубине 1.
+	MaxDepth int
+	Sort     string
+}
+
+func parseTreeOptions(query url.Values) (TreeOptions, error) {
+	opts := TreeOptions{Sort: TreeSortTime}
+
+	if d := query.Get("max_depth"); d != "" {
+		depth, err := strconv.Atoi(d)
+		if err != nil || depth < 0 {
+			return opts, fmt.Errorf("max_depth must be a non-negative integer")
+		}
+		opts.MaxDepth = depth
+	}
+
+	switch s := query.Get("sort"); s {
+	case "", TreeSortTime:
+	case TreeSortScore:
+		opts.Sort = TreeSortScore
+	default:
+		return opts, fmt.Errorf("unknown sort %q", s)
+	}
+
+	return opts, nil
+}
+
+// buildCommentTree собирает дерево из плоского списка комментариев.
+// Ответы, родитель которых отсутствует в списке, считаются осиротевшими
+// и поднимаются на верхний уровень. В список попадают только одобренные комментарии,
+// поэтому осиротевшими считаются и ответы на скрытые модерацией комментарии. Так же поднимается по одному комментарию из каждого цикла
+// ответов, иначе цикл не попал бы в дерево, хотя учтен в Total.
+func buildCommentTree(comments []Comment, opts TreeOptions) CommentTreeResponse {
+	nodes := make(map[int]*CommentNode, len(comments))
+	for _, c := range comments {
+		nodes[c.ID] = &CommentNode{Comment: c, Replies: []*CommentNode{}}
+	}
+
+	response := CommentTreeResponse{
+		Comments: []*CommentNode{},
+		Total:    len(comments),
+	}
+	for _, c := range comments {
+		node := nodes[c.ID]
+		if c.ParentCommentID == nil {
+			response.Comments = append(response.Comments, node)
+			continue
+		}
+		parent, ok := nodes[*c.ParentCommentID]
+		if !ok || parent == node {
+			node.Orphan = true
+			response.Orphans++
+			response.Comments = append(response.Comments, node)
+			continue
+		}
+		parent.Replies = append(parent.Replies, node)
+	}
+	breakCycles(comments, nodes, &response)
+
+	for _, node := range response.Comments {
+		countReplies(node)
+	}
+	sortNodes(response.Comments, opts.Sort)
+	for _, node := range response.Comments {
+		arrangeNode(node, 1, opts)
+	}
+
+	return response
+}
+
+// breakCycles находит комментарии, недостижимые с верхнего уровня. Такие комментарии лежат в цикле
+// ответов или под ним: комментарий цикла отцепляется от родителя и поднимается на верхний уровень как осиротевший.
+func breakCycles(comments []Comment, nodes map[int]*CommentNode, response *CommentTreeResponse) {
+	reachable := make(map[*CommentNode]bool, len(nodes))
+	var mark func(node *CommentNode)
+	mark = func(node *CommentNode) {
+		reachable[node] = true
+		for _, reply := range node.Replies {
+			mark(reply)
+		}
+	}
+	for _, node := range response.Comments {
+		mark(node)
+	}
+
+	for _, c := range comments {
+		if reachable[nodes[c.ID]] {
+			continue
+		}
+		// Поднимаемся по родителям до первого повтора - это комментарий цикла
+		seen := map[*CommentNode]bool{}
+		node := nodes[c.ID]
+		for !seen[node] {
+			seen[node] = true
+			node = nodes[*node.ParentCommentID]
+		}
+
+		parent := nodes[*node.ParentCommentID]
+		for i, reply := range parent.Replies {
+			if reply == node {
+				parent.Replies = append(parent.Replies[:i], parent.Replies[i+1:]...)
+				break
+			}
+		}
+		node.Orphan = true
+		response.Orphans++
+		response.Comments = append(response.Comments, node)
+		mark(node)
+	}
+}
+
+// countReplies считает рейтинг ветки - общее число ответов под комментарием
+func countReplies(node *CommentNode) int {
+	node.Score = 0
+	for _, reply := range node.Replies {
+		node.Score += 1 + countReplies(reply)
+	}
+	return node.Score
+}
+
+func arrangeNode(node *CommentNode, depth int, opts TreeOptions) {
+	if opts.MaxDepth > 0 && depth >= opts.MaxDepth && len(node.Replies) > 0 {
+		node.MoreReplies = node.Score
+		node.Replies = []*CommentNode{}
+		return
+	}
+	sortNodes(node.Replies, opts.Sort)
+	for _, reply := range node.Replies {
+		arrangeNode(reply, depth+1, opts)
+	}
+}
+
+func sortNodes(nodes []*CommentNode, order string) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if order == TreeSortScore && nodes[i].Score != nodes[j].Score {
+			return nodes[i].Score > nodes[j].Score
+		}
+		return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testComment - комментарий id с родителем parent (0 - корневой), созданный через id минут после начала
+func testComment(id, parent int) Comment {
+	c := Comment{ID: id, NewsID: 1, CreatedAt: time.Date(2024, 1, 1, 0, id, 0, 0, time.UTC), Status: StatusApproved}
+	if parent != 0 {
+		c.ParentCommentID = &parent
+	}
+	return c
+}
+
+// treeString описывает дерево: id, * у осиротевших, +N у обрезанных ответов, ответы в скобках
+func treeString(nodes []*CommentNode) string {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		s := fmt.Sprint(node.ID)
+		if node.Orphan {
+			s += "*"
+		}
+		if node.MoreReplies > 0 {
+			s += fmt.Sprintf("+%d", node.MoreReplies)
+		}
+		if len(node.Replies) > 0 {
+			s += "(" + treeString(node.Replies) + ")"
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestBuildCommentTree(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []Comment
+		opts     TreeOptions
+		want     string
+		orphans  int
+	}{
+		{
+			name:     "nested replies by time",
+			comments: []Comment{testComment(1, 0), testComment(2, 1), testComment(3, 0), testComment(4, 2), testComment(5, 1)},
+			opts:     TreeOptions{Sort: TreeSortTime},
+			want:     "1(2(4) 5) 3",
+		},
+		{
+			name:     "missing parent",
+			comments: []Comment{testComment(1, 0), testComment(3, 2), testComment(4, 3)},
+			opts:     TreeOptions{Sort: TreeSortTime},
+			want:     "1 3*(4)",
+			orphans:  1,
+		},
+		{
+			name:     "self reference",
+			comments: []Comment{testComment(1, 1), testComment(2, 1)},
+			opts:     TreeOptions{Sort: TreeSortTime},
+			want:     "1*(2)",
+			orphans:  1,
+		},
+		{
+			name:     "cycle",
+			comments: []Comment{testComment(1, 0), testComment(2, 3), testComment(3, 2), testComment(4, 3)},
+			opts:     TreeOptions{Sort: TreeSortTime},
+			want:     "1 2*(3(4))",
+			orphans:  1,
+		},
+		{
+			name:     "two cycles",
+			comments: []Comment{testComment(1, 2), testComment(2, 1), testComment(3, 4), testComment(4, 3)},
+			opts:     TreeOptions{Sort: TreeSortTime},
+			want:     "1*(2) 3*(4)",
+			orphans:  2,
+		},
+		{
+			name:     "depth cut-off",
+			comments: []Comment{testComment(1, 0), testComment(2, 1), testComment(3, 2), testComment(4, 3), testComment(5, 0)},
+			opts:     TreeOptions{Sort: TreeSortTime, MaxDepth: 2},
+			want:     "1(2+2) 5",
+		},
+		{
+			name:     "depth one",
+			comments: []Comment{testComment(1, 0), testComment(2, 1), testComment(3, 1)},
+			opts:     TreeOptions{Sort: TreeSortTime, MaxDepth: 1},
+			want:     "1+2",
+		},
+		{
+			name:     "score order",
+			comments: []Comment{testComment(1, 0), testComment(2, 0), testComment(3, 2), testComment(4, 2), testComment(5, 0), testComment(6, 5)},
+			opts:     TreeOptions{Sort: TreeSortScore},
+			want:     "2(3 4) 5(6) 1",
+		},
+		{
+			name:     "score ties by time",
+			comments: []Comment{testComment(3, 0), testComment(1, 0), testComment(2, 0)},
+			opts:     TreeOptions{Sort: TreeSortScore},
+			want:     "1 2 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildCommentTree(tt.comments, tt.opts)
+			if got := treeString(tree.Comments); got != tt.want {
+				t.Errorf("tree = %s, want %s", got, tt.want)
+			}
+			if tree.Total != len(tt.comments) {
+				t.Errorf("total = %d, want %d", tree.Total, len(tt.comments))
+			}
+			if tree.Orphans != tt.orphans {
+				t.Errorf("orphans = %d, want %d", tree.Orphans, tt.orphans)
+			}
+		})
+	}
+}
+
+func TestBuildCommentTreeScore(t *testing.T) {
+	comments := []Comment{testComment(1, 0), testComment(2, 1), testComment(3, 2), testComment(4, 1)}
+	tree := buildCommentTree(comments, TreeOptions{Sort: TreeSortTime})
+	root := tree.Comments[0]
+	if root.Score != 3 || root.Replies[0].Score != 1 || root.Replies[1].Score != 0 {
+		t.Errorf("scores = %d, %d, %d, want 3, 1, 0", root.Score, root.Replies[0].Score, root.Replies[1].Score)
+	}
+}
+
+func TestParseTreeOptions(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    TreeOptions
+		wantErr bool
+	}{
+		{"", TreeOptions{Sort: TreeSortTime}, false},
+		{"max_depth=3&sort=score", TreeOptions{MaxDepth: 3, Sort: TreeSortScore}, false},
+		{"max_depth=-1", TreeOptions{}, true},
+		{"max_depth=x", TreeOptions{}, true},
+		{"sort=likes", TreeOptions{}, true},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got, err := parseTreeOptions(query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q: options = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -4,15 +4,17 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DB struct {
-	conn *sql.DB
+	conn        *sql.DB
+	searchLangs []string
 }
 
-func NewDB(dsn string) (*DB, error) {
+func NewDB(dsn string, searchLangs []string) (*DB, error) {
 	conn, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -22,10 +24,17 @@ func NewDB(dsn string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db := &DB{conn: conn}
+	if len(searchLangs) == 0 {
+		searchLangs = defaultSearchLanguages
+	}
+
+	db := &DB{conn: conn, searchLangs: searchLangs}
 	if err := db.initSchema(); err != nil {
 		return nil, fmt.Errorf("failed to init schema: %w", err)
 	}
+	if err := db.initSearch(); err != nil {
+		return nil, fmt.Errorf("failed to init search: %w", err)
+	}
 
 	return db, nil
 }
@@ -46,6 +55,21 @@ func (db *DB) initSchema() error {
 		return fmt.Errorf("failed to create news table: %w", err)
 	}
 
+	// Раздел новости: по нему API Gateway выбирает политику модерации комментариев
+	_, err = db.conn.Exec("ALTER TABLE news ADD COLUMN IF NOT EXISTS section TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return fmt.Errorf("failed to add news section column: %w", err)
+	}
+
+	// Ссылка служит ключом дедупликации для новостей из фидов
+	_, err = db.conn.Exec("CREATE UNIQUE INDEX IF NOT EXISTS news_link_idx ON news (link)")
+	if err != nil {
+		if dupErr := db.checkDuplicateLinks(); dupErr != nil {
+			return dupErr
+		}
+		return fmt.Errorf("failed to create news link index: %w", err)
+	}
+
 	// Добавим тестовые данные, если таблица пустая
 	var count int
 	err = db.conn.QueryRow("SELECT COUNT(*) FROM news").Scan(&count)
@@ -56,19 +80,19 @@ func (db *DB) initSchema() error {
 	if count == 0 {
 		log.Println("Adding sample news data...")
 		sampleNews := []struct {
-			title, content, link, source string
+			title, content, link, source, section string
 		}{
-			{"Новость о Go", "Go - отличный язык программирования", "https://example.com/go", "Example"},
-			{"Новость о микросервисах", "Микросервисная архитектура становится популярной", "https://example.com/microservices", "Example"},
-			{"Новость о PostgreSQL", "PostgreSQL - мощная реляционная БД", "https://example.com/postgres", "Example"},
-			{"Новость о Docker", "Docker упрощает развертывание приложений", "https://example.com/docker", "Example"},
-			{"Новость о Kubernetes", "Kubernetes для оркестрации контейнеров", "https://example.com/k8s", "Example"},
+			{"Новость о Go", "Go - отличный язык программирования", "https://example.com/go", "Example", "tech"},
+			{"Новость о микросервисах", "Микросервисная архитектура становится популярной", "https://example.com/microservices", "Example", "tech"},
+			{"Новость о PostgreSQL", "PostgreSQL - мощная реляционная БД", "https://example.com/postgres", "Example", "tech"},
+			{"Новость о Docker", "Docker упрощает развертывание приложений", "https://example.com/docker", "Example", "tech"},
+			{"Новость о Kubernetes", "Kubernetes для оркестрации контейнеров", "https://example.com/k8s", "Example", "tech"},
 		}
 
 		for _, n := range sampleNews {
 			_, err = db.conn.Exec(
-				"INSERT INTO news (title, content, pub_time, link, source) VALUES ($1, $2, NOW(), $3, $4)",
-				n.title, n.content, n.link, n.source,
+				"INSERT INTO news (title, content, pub_time, link, source, section) VALUES ($1, $2, NOW(), $3, $4, $5)",
+				n.title, n.content, n.link, n.source, n.section,
 			)
 			if err != nil {
 				return fmt.Errorf("failed to insert sample news: %w", err)
@@ -79,30 +103,82 @@ func (db *DB) initSchema() error {
 	return nil
 }
 
-func (db *DB) GetNews(page, pageSize int, search string) ([]NewsShortDetailed, int, error) {
-	offset := (page - 1) * pageSize
-	var rows *sql.Rows
-	var err error
-
-	if search != "" {
-		query := `
-			SELECT id, title, content, pub_time 
-			FROM news 
-			WHERE title ILIKE $1 
-			ORDER BY pub_time DESC 
-			LIMIT $2 OFFSET $3
-		`
-		rows, err = db.conn.Query(query, "%"+search+"%", pageSize, offset)
-	} else {
-		query := `
-			SELECT id, title, content, pub_time 
-			FROM news 
-			ORDER BY pub_time DESC 
-			LIMIT $1 OFFSET $2
-		`
-		rows, err = db.conn.Query(query, pageSize, offset)
+// checkDuplicateLinks возвращает ошибку, если в базе, созданной до индекса по ссылке, есть новости с одинаковой ссылкой.
+// Дубликаты не удаляются автоматически: на них могут ссылаться комментарии в CommentsService.
+func (db *DB) checkDuplicateLinks() error {
+	var links int
+	var example sql.NullString
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*), MIN(link) FROM (
+			SELECT link FROM news WHERE link IS NOT NULL GROUP BY link HAVING COUNT(*) > 1
+		) duplicates`).Scan(&links, &example)
+	if err != nil {
+		return fmt.Errorf("failed to check duplicate news links: %w", err)
+	}
+	if links == 0 {
+		return nil
+	}
+	return fmt.Errorf("cannot create unique index on news.link: %d links are used by several news, e.g. %q; "+
+		"remove the duplicates (see README) and restart", links, example.String)
+}
+
+func (db *DB) GetNews(filter NewsFilter) ([]NewsShortDetailed, int, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	from := "news"
+	snippet := "''"
+	var conditions []string
+	if filter.Search != "" {
+		from = fmt.Sprintf("news, (SELECT %s AS q) AS search_query", db.searchQueryExpr(len(args)+1))
+		args = append(args, filter.Search)
+		snippet = db.headlineExpr("q")
+		conditions = append(conditions, "search_vector @@ q")
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "pub_time >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "pub_time <= "+arg(*filter.To))
+	}
+	if len(filter.Sources) > 0 {
+		conditions = append(conditions, "source = ANY("+arg(pq.Array(filter.Sources))+")")
+	}
+	if len(filter.Sections) > 0 {
+		conditions = append(conditions, "section = ANY("+arg(pq.Array(filter.Sections))+")")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	err := db.conn.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s %s", from, where), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count news: %w", err)
+	}
+
+	orderBy := "pub_time DESC, id DESC"
+	switch filter.Sort {
+	case SortPubTimeAsc:
+		orderBy = "pub_time ASC, id ASC"
+	case SortRelevance:
+		orderBy = "ts_rank_cd(search_vector, q) DESC, pub_time DESC, id DESC"
 	}
 
+	query := fmt.Sprintf(`
+		SELECT id, title, content, pub_time, section, %s
+		FROM %s
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, snippet, from, where, orderBy, arg(filter.PageSize), arg((filter.Page-1)*filter.PageSize))
+
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to query news: %w", err)
 	}
@@ -111,33 +187,22 @@ func (db *DB) GetNews(page, pageSize int, search string) ([]NewsShortDetailed, i
 	var news []NewsShortDetailed
 	for rows.Next() {
 		var n NewsShortDetailed
-		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.PubTime); err != nil {
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.PubTime, &n.Section, &n.Snippet); err != nil {
 			return nil, 0, fmt.Errorf("failed to scan news: %w", err)
 		}
+		n.Snippet = highlightSnippet(n.Snippet)
 		news = append(news, n)
 	}
 
-	var total int
-	countQuery := "SELECT COUNT(*) FROM news"
-	if search != "" {
-		countQuery = "SELECT COUNT(*) FROM news WHERE title ILIKE $1"
-		err = db.conn.QueryRow(countQuery, "%"+search+"%").Scan(&total)
-	} else {
-		err = db.conn.QueryRow(countQuery).Scan(&total)
-	}
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to count news: %w", err)
-	}
-
 	return news, total, nil
 }
 
 func (db *DB) GetNewsByID(id int) (*NewsFullDetailed, error) {
 	var news NewsFullDetailed
 	err := db.conn.QueryRow(
-		"SELECT id, title, content, pub_time, link, source FROM news WHERE id = $1",
+		"SELECT id, title, content, pub_time, link, source, section FROM news WHERE id = $1",
 		id,
-	).Scan(&news.ID, &news.Title, &news.Content, &news.PubTime, &news.Link, &news.Source)
+	).Scan(&news.ID, &news.Title, &news.Content, &news.PubTime, &news.Link, &news.Source, &news.Section)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -149,6 +214,56 @@ func (db *DB) GetNewsByID(id int) (*NewsFullDetailed, error) {
 	return &news, nil
 }
 
+// UpsertNews сохраняет новости, обновляя уже существующие по ссылке.
+// Новость с нулевым PubTime сохраняется с текущим временем, у существующей время публикации не меняется.
+func (db *DB) UpsertNews(items []NewsFullDetailed) (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO news (title, content, pub_time, link, source, section)
+		VALUES ($1, $2, COALESCE($3::TIMESTAMP, NOW()), $4, $5, $6)
+		ON CONFLICT (link) DO UPDATE SET
+			title = EXCLUDED.title,
+			content = EXCLUDED.content,
+			pub_time = COALESCE($3::TIMESTAMP, news.pub_time),
+			source = EXCLUDED.source,
+			section = EXCLUDED.section
+		WHERE (news.title, news.content, news.pub_time, news.source, news.section)
+			IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.content, COALESCE($3::TIMESTAMP, news.pub_time), EXCLUDED.source, EXCLUDED.section)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	upserted := 0
+	for _, n := range items {
+		var pubTime sql.NullTime
+		if !n.PubTime.IsZero() {
+			pubTime = sql.NullTime{Time: n.PubTime, Valid: true}
+		}
+		res, err := stmt.Exec(n.Title, n.Content, pubTime, n.Link, n.Source, n.Section)
+		if err != nil {
+			return 0, fmt.Errorf("failed to upsert news %q: %w", n.Link, err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		upserted += int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return upserted, nil
+}
+
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultFeedsInterval = 5 * time.Minute
+
+// NewsStore - хранилище, в которое поллер складывает разобранные новости
+type NewsStore interface {
+	UpsertNews(items []NewsFullDetailed) (int, error)
+}
+
+// FeedState - состояние опроса одного фида
+type FeedState struct {
+	URL               string    `json:"url"`
+	Section           string    `json:"section,omitempty"`
+	Title             string    `json:"title,omitempty"`
+	LastFetch         time.Time `json:"last_fetch,omitempty"`
+	LastSuccess       time.Time `json:"last_success,omitempty"`
+	LastError         string    `json:"last_error,omitempty"`
+	ConsecutiveErrors int       `json:"consecutive_errors"`
+	ItemsLastFetch    int       `json:"items_last_fetch"`
+	ItemsUpserted     int       `json:"items_upserted"`
+
+	etag         string
+	lastModified string
+}
+
+// FeedPoller периодически читает RSS 2.0 и Atom фиды и сохраняет новости в NewsStore
+type FeedPoller struct {
+	feeds    []string
+	interval time.Duration
+	client   *http.Client
+	store    NewsStore
+
+	mu     sync.RWMutex
+	states map[string]*FeedState
+}
+
+// NewFeedPoller принимает адреса фидов. Раздел новостей фида задается префиксом: "politics=https://example.com/rss".
+func NewFeedPoller(feeds []string, interval time.Duration, store NewsStore) *FeedPoller {
+	if interval <= 0 {
+		interval = defaultFeedsInterval
+	}
+	urls := make([]string, 0, len(feeds))
+	states := make(map[string]*FeedState, len(feeds))
+	for _, feed := range feeds {
+		url, section := parseFeedSpec(feed)
+		urls = append(urls, url)
+		states[url] = &FeedState{URL: url, Section: section}
+	}
+	return &FeedPoller{
+		feeds:    urls,
+		interval: interval,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		store:  store,
+		states: states,
+	}
+}
+
+// Run опрашивает фиды сразу и затем каждые interval, пока не отменен ctx
+func (p *FeedPoller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.PollOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollOnce выполняет один проход по всем фидам
+func (p *FeedPoller) PollOnce(ctx context.Context) {
+	for _, url := range p.feeds {
+		if ctx.Err() != nil {
+			return
+		}
+		p.pollFeed(ctx, url)
+	}
+}
+
+// States возвращает копию состояния всех фидов
+func (p *FeedPoller) States() []FeedState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	states := make([]FeedState, 0, len(p.feeds))
+	for _, url := range p.feeds {
+		states = append(states, *p.states[url])
+	}
+	return states
+}
+
+// parseFeedSpec разбирает "раздел=адрес". Знак "=" после начала адреса (например в параметрах запроса) разделом не считается.
+func parseFeedSpec(spec string) (url, section string) {
+	name, rest, ok := strings.Cut(spec, "=")
+	if !ok || strings.ContainsAny(name, ":/?") {
+		return spec, ""
+	}
+	return strings.TrimSpace(rest), strings.TrimSpace(name)
+}
+
+func (p *FeedPoller) pollFeed(ctx context.Context, url string) {
+	p.mu.RLock()
+	state := p.states[url]
+	etag, lastModified, section := state.etag, state.lastModified, state.Section
+	p.mu.RUnlock()
+
+	result, err := p.fetch(ctx, url, etag, lastModified)
+	upserted := 0
+	if err == nil && len(result.items) > 0 {
+		for i := range result.items {
+			result.items[i].Section = section
+		}
+		upserted, err = p.store.UpsertNews(result.items)
+		if err != nil {
+			err = fmt.Errorf("failed to store items: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state.LastFetch = time.Now()
+	if err != nil {
+		state.LastError = err.Error()
+		state.ConsecutiveErrors++
+		slog.Error("Failed to poll feed", "url", url, "error", err, "consecutive_errors", state.ConsecutiveErrors)
+		return
+	}
+
+	state.LastSuccess = state.LastFetch
+	state.LastError = ""
+	state.ConsecutiveErrors = 0
+	if result.notModified {
+		return
+	}
+	state.etag = result.etag
+	state.lastModified = result.lastModified
+	if result.title != "" {
+		state.Title = result.title
+	}
+	state.ItemsLastFetch = len(result.items)
+	state.ItemsUpserted += upserted
+	slog.Info("Feed polled", "url", url, "items", len(result.items), "upserted", upserted)
+}
+
+type fetchResult struct {
+	title        string
+	items        []NewsFullDetailed
+	etag         string
+	lastModified string
+	notModified  bool
+}
+
+func (p *FeedPoller) fetch(ctx context.Context, url, etag, lastModified string) (*fetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml, text/xml")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &fetchResult{notModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	title, items, err := parseFeed(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if items[i].Source == "" {
+			items[i].Source = title
+		}
+	}
+
+	return &fetchResult{
+		title:        title,
+		items:        items,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	GUID        struct {
+		Value       string `xml:",chardata"`
+		IsPermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+}
+
+type atomDocument struct {
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	ID        string `xml:"id"`
+}
+
+// parseFeed разбирает RSS 2.0 или Atom документ и возвращает заголовок фида и его элементы.
+// Элементы без ссылки пропускаются, повторяющиеся ссылки схлопываются.
+func parseFeed(r io.Reader) (string, []NewsFullDetailed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var title string
+	var items []NewsFullDetailed
+	switch root {
+	case "rss":
+		var doc rssDocument
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return "", nil, fmt.Errorf("failed to parse rss: %w", err)
+		}
+		title = cleanText(doc.Channel.Title)
+		for _, item := range doc.Channel.Items {
+			items = append(items, item.toNews())
+		}
+	case "feed":
+		var doc atomDocument
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return "", nil, fmt.Errorf("failed to parse atom: %w", err)
+		}
+		title = cleanText(doc.Title)
+		for _, entry := range doc.Entries {
+			items = append(items, entry.toNews())
+		}
+	default:
+		return "", nil, fmt.Errorf("unsupported feed format: <%s>", root)
+	}
+
+	seen := make(map[string]bool, len(items))
+	result := items[:0]
+	for _, item := range items {
+		if item.Link == "" || seen[item.Link] {
+			continue
+		}
+		seen[item.Link] = true
+		result = append(result, item)
+	}
+
+	return title, result, nil
+}
+
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse feed: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func (item rssItem) toNews() NewsFullDetailed {
+	link := strings.TrimSpace(item.Link)
+	guid := strings.TrimSpace(item.GUID.Value)
+	if link == "" && item.GUID.IsPermaLink != "false" && strings.HasPrefix(guid, "http") {
+		link = guid
+	}
+
+	content := item.Encoded
+	if strings.TrimSpace(content) == "" {
+		content = item.Description
+	}
+
+	return NewsFullDetailed{
+		Title:   cleanText(item.Title),
+		Content: cleanText(content),
+		PubTime: parseFeedTime(item.PubDate),
+		Link:    link,
+	}
+}
+
+func (entry atomEntry) toNews() NewsFullDetailed {
+	var link string
+	for _, l := range entry.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			link = strings.TrimSpace(l.Href)
+			break
+		}
+	}
+	if link == "" && strings.HasPrefix(entry.ID, "http") {
+		link = strings.TrimSpace(entry.ID)
+	}
+
+	content := entry.Content
+	if strings.TrimSpace(content) == "" {
+		content = entry.Summary
+	}
+
+	published := entry.Published
+	if published == "" {
+		published = entry.Updated
+	}
+
+	return NewsFullDetailed{
+		Title:   cleanText(entry.Title),
+		Content: cleanText(content),
+		PubTime: parseFeedTime(published),
+		Link:    link,
+	}
+}
+
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseFeedTime разбирает дату публикации. Если даты нет или формат неизвестен, возвращает нулевое время:
+// новая новость получит время сохранения, а у сохраненной останется прежнее.
+func parseFeedTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+var (
+	htmlTagRe    = regexp.MustCompile(`<[^>]*>`)
+	whitespaceRe = regexp.MustCompile(`\s+`)
+)
+
+// cleanText убирает HTML-разметку и лишние пробелы. Сущности раскрываются до удаления тегов,
+// иначе экранированная разметка (&lt;script&gt;) превратилась бы в теги.
+func cleanText(s string) string {
+	s = html.UnescapeString(s)
+	s = htmlTagRe.ReplaceAllString(s, " ")
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(s, " "))
+}
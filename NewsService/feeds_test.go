@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// memoryStore - NewsStore, который запоминает сохраненные новости
+type memoryStore struct {
+	items []NewsFullDetailed
+}
+
+func (s *memoryStore) UpsertNews(items []NewsFullDetailed) (int, error) {
+	s.items = append(s.items, items...)
+	return len(items), nil
+}
+
+// feedServer отдает файл фикстуры и отвечает 304 на запрос с тем же ETag
+func feedServer(t *testing.T, fixture string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFeedPollerRSS(t *testing.T) {
+	server := feedServer(t, "testdata/rss.xml")
+	store := &memoryStore{}
+	poller := NewFeedPoller([]string{"politics=" + server.URL}, time.Minute, store)
+	poller.PollOnce(context.Background())
+
+	if len(store.items) != 2 {
+		t.Fatalf("stored %d items, want 2: %+v", len(store.items), store.items)
+	}
+	first, second := store.items[0], store.items[1]
+	if first.Title != "First news" {
+		t.Errorf("title = %q", first.Title)
+	}
+	if first.Content != "Hello & welcome alert(1)" {
+		t.Errorf("content = %q, escaped markup must be stripped", first.Content)
+	}
+	if want := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC); !first.PubTime.Equal(want) {
+		t.Errorf("pub time = %v, want %v", first.PubTime, want)
+	}
+	if first.Source != "Example & News" || first.Section != "politics" {
+		t.Errorf("source = %q, section = %q", first.Source, first.Section)
+	}
+	if second.Link != "https://example.com/news/2" || second.Content != "Body with spaces" {
+		t.Errorf("second = %+v", second)
+	}
+	if !second.PubTime.IsZero() {
+		t.Errorf("item without date: pub time = %v, want zero to keep the stored time", second.PubTime)
+	}
+
+	// Повторный опрос с тем же ETag ничего не сохраняет
+	poller.PollOnce(context.Background())
+	if len(store.items) != 2 {
+		t.Errorf("stored %d items after 304, want 2", len(store.items))
+	}
+	state := poller.States()[0]
+	if state.Title != "Example & News" || state.ConsecutiveErrors != 0 || state.ItemsUpserted != 2 {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestFeedPollerAtom(t *testing.T) {
+	server := feedServer(t, "testdata/atom.xml")
+	store := &memoryStore{}
+	NewFeedPoller([]string{server.URL}, time.Minute, store).PollOnce(context.Background())
+
+	if len(store.items) != 1 {
+		t.Fatalf("stored %d items, want 1", len(store.items))
+	}
+	item := store.items[0]
+	if item.Link != "https://example.com/atom/1" || item.Content != "Summary text" || item.Source != "Atom News" {
+		t.Errorf("item = %+v", item)
+	}
+	if want := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC); !item.PubTime.Equal(want) {
+		t.Errorf("pub time = %v, want %v", item.PubTime, want)
+	}
+}
+
+func TestFeedPollerError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	poller := NewFeedPoller([]string{server.URL}, time.Minute, &memoryStore{})
+	poller.PollOnce(context.Background())
+
+	state := poller.States()[0]
+	if state.ConsecutiveErrors != 1 || state.LastError == "" {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	tests := map[string]string{
+		"<p>Hello</p>":                          "Hello",
+		"&lt;script&gt;alert(1)&lt;/script&gt;": "alert(1)",
+		"Tom &amp; Jerry":                       "Tom & Jerry",
+		"  many\n\tspaces  ":                    "many spaces",
+	}
+	for in, want := range tests {
+		if got := cleanText(in); got != want {
+			t.Errorf("cleanText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
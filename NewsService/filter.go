@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+	// maxPage ограничивает номер страницы, чтобы смещение (page-1)*page_size не переполнялось
+	maxPage = 100000
+)
+
+const (
+	SortPubTimeDesc = "pub_time_desc"
+	SortPubTimeAsc  = "pub_time_asc"
+	SortRelevance   = "relevance"
+)
+
+// NewsFilter - параметры выборки списка новостей
+type NewsFilter struct {
+	Search   string
+	From     *time.Time
+	To       *time.Time
+	Sources  []string
+	Sections []string
+	Sort     string
+	Page     int
+	PageSize int
+}
+
+// parseNewsFilter разбирает параметры запроса /news.
+// Номер страницы, как и раньше, при ошибке сбрасывается на первую, а больше maxPage - ограничивается maxPage.
+func parseNewsFilter(query url.Values) (NewsFilter, error) {
+	filter := NewsFilter{
+		Search:   query.Get("s"),
+		Page:     1,
+		PageSize: defaultPageSize,
+	}
+
+	if p := query.Get("page"); p != "" {
+		if page, err := strconv.Atoi(p); err == nil && page >= 1 {
+			filter.Page = min(page, maxPage)
+		}
+	}
+
+	if ps := query.Get("page_size"); ps != "" {
+		pageSize, err := strconv.Atoi(ps)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return filter, fmt.Errorf("page_size must be an integer between 1 and %d", maxPageSize)
+		}
+		filter.PageSize = pageSize
+	}
+
+	if from := query.Get("from"); from != "" {
+		t, err := parseFilterTime(from, false)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %w", err)
+		}
+		filter.From = &t
+	}
+
+	if to := query.Get("to"); to != "" {
+		t, err := parseFilterTime(to, true)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %w", err)
+		}
+		filter.To = &t
+	}
+
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return filter, fmt.Errorf("from must not be after to")
+	}
+
+	for _, source := range query["source"] {
+		if source = strings.TrimSpace(source); source != "" {
+			filter.Sources = append(filter.Sources, source)
+		}
+	}
+
+	for _, section := range query["section"] {
+		if section = strings.TrimSpace(section); section != "" {
+			filter.Sections = append(filter.Sections, section)
+		}
+	}
+
+	filter.Sort = query.Get("sort")
+	switch filter.Sort {
+	case "":
+		filter.Sort = SortPubTimeDesc
+		if filter.Search != "" {
+			filter.Sort = SortRelevance
+		}
+	case SortPubTimeDesc, SortPubTimeAsc:
+	case SortRelevance:
+		if filter.Search == "" {
+			return filter, fmt.Errorf("sort=relevance requires s")
+		}
+	default:
+		return filter, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+
+	return filter, nil
+}
+
+// parseFilterTime принимает RFC3339 или дату YYYY-MM-DD.
+// Дата без времени в качестве верхней границы включает весь день.
+func parseFilterTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 or YYYY-MM-DD, got %q", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Microsecond)
+	}
+	return t, nil
+}
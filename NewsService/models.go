@@ -7,6 +7,8 @@ type NewsShortDetailed struct {
 	Title   string    `json:"title"`
 	Content string    `json:"content"`
 	PubTime time.Time `json:"pub_time"`
+	Section string    `json:"section,omitempty"`
+	Snippet string    `json:"snippet,omitempty"`
 }
 
 type NewsFullDetailed struct {
@@ -16,6 +18,7 @@ type NewsFullDetailed struct {
 	PubTime   time.Time `json:"pub_time"`
 	Link      string    `json:"link"`
 	Source    string    `json:"source"`
+	Section   string    `json:"section,omitempty"`
 }
 
 type NewsListResponse struct {
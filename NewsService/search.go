@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var defaultSearchLanguages = []string{"russian", "english"}
+
+var searchLanguageRe = regexp.MustCompile(`^[a-z_]+$`)
+
+// Совпадения в сниппете ts_headline отмечаются символами из области частного использования Unicode,
+// а не тегами: текст новости экранируется уже после ts_headline, и только затем маркеры заменяются на <b>
+const (
+	snippetStartSel = "\uE000"
+	snippetStopSel  = "\uE001"
+	headlineOptions = "StartSel=" + snippetStartSel + ", StopSel=" + snippetStopSel + ", MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter= ... "
+)
+
+var snippetReplacer = strings.NewReplacer(snippetStartSel, "<b>", snippetStopSel, "</b>")
+
+// highlightSnippet экранирует HTML в сниппете и заменяет маркеры совпадений на <b>...</b>
+func highlightSnippet(snippet string) string {
+	return snippetReplacer.Replace(html.EscapeString(snippet))
+}
+
+// initSearch готовит полнотекстовый поиск: колонку search_vector, GIN-индекс
+// и триггер, который пересчитывает вектор при вставке и изменении новости.
+// Если набор языков поменялся с прошлого запуска, векторы пересчитываются для всех строк.
+func (db *DB) initSearch() error {
+	for _, lang := range db.searchLangs {
+		if !searchLanguageRe.MatchString(lang) {
+			return fmt.Errorf("invalid search language %q", lang)
+		}
+		var exists bool
+		err := db.conn.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = $1)", lang).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check search language: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("unknown text search configuration %q", lang)
+		}
+	}
+
+	vector := db.searchVectorExpr("NEW.title", "NEW.content")
+	queries := []string{
+		"ALTER TABLE news ADD COLUMN IF NOT EXISTS search_vector tsvector",
+		"CREATE INDEX IF NOT EXISTS news_search_idx ON news USING GIN (search_vector)",
+		`CREATE TABLE IF NOT EXISTS news_search_config (
+			id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+			languages TEXT NOT NULL
+		)`,
+		`CREATE OR REPLACE FUNCTION news_search_vector_update() RETURNS trigger AS $$
+		BEGIN
+			NEW.search_vector := ` + vector + `;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql`,
+		"DROP TRIGGER IF EXISTS news_search_vector_trigger ON news",
+		`CREATE TRIGGER news_search_vector_trigger
+			BEFORE INSERT OR UPDATE OF title, content ON news
+			FOR EACH ROW EXECUTE FUNCTION news_search_vector_update()`,
+	}
+	for _, query := range queries {
+		if _, err := db.conn.Exec(query); err != nil {
+			return fmt.Errorf("failed to init search: %w", err)
+		}
+	}
+
+	languages := strings.Join(db.searchLangs, ",")
+	var stored string
+	err := db.conn.QueryRow("SELECT languages FROM news_search_config").Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get search config: %w", err)
+	}
+	if stored == languages {
+		return nil
+	}
+
+	_, err = db.conn.Exec("UPDATE news SET search_vector = " + db.searchVectorExpr("title", "content"))
+	if err != nil {
+		return fmt.Errorf("failed to rebuild search vectors: %w", err)
+	}
+	_, err = db.conn.Exec(
+		"INSERT INTO news_search_config (languages) VALUES ($1) ON CONFLICT (id) DO UPDATE SET languages = EXCLUDED.languages",
+		languages,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save search config: %w", err)
+	}
+
+	return nil
+}
+
+// searchVectorExpr строит выражение tsvector по всем языкам поиска:
+// заголовок получает вес A, текст новости - вес B.
+func (db *DB) searchVectorExpr(title, content string) string {
+	parts := make([]string, 0, len(db.searchLangs)*2)
+	for _, lang := range db.searchLangs {
+		parts = append(parts,
+			fmt.Sprintf("setweight(to_tsvector('%s', coalesce(%s, '')), 'A')", lang, title),
+			fmt.Sprintf("setweight(to_tsvector('%s', coalesce(%s, '')), 'B')", lang, content),
+		)
+	}
+	return strings.Join(parts, " || ")
+}
+
+// searchQueryExpr строит tsquery из пользовательской строки поиска (параметр с номером arg)
+func (db *DB) searchQueryExpr(arg int) string {
+	parts := make([]string, 0, len(db.searchLangs))
+	for _, lang := range db.searchLangs {
+		parts = append(parts, fmt.Sprintf("websearch_to_tsquery('%s', $%d)", lang, arg))
+	}
+	return strings.Join(parts, " || ")
+}
+
+// headlineExpr строит сниппет текста новости с подсвеченными совпадениями
+func (db *DB) headlineExpr(query string) string {
+	return fmt.Sprintf("ts_headline('%s', content, %s, '%s')", db.searchLangs[0], query, headlineOptions)
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := map[string]string{
+		"plain text": "plain text",
+		"found " + snippetStartSel + "word" + snippetStopSel + " here":      "found <b>word</b> here",
+		snippetStartSel + "<script>" + snippetStopSel + "alert(1)</script>": "<b>&lt;script&gt;</b>alert(1)&lt;/script&gt;",
+		"a < b & \"c\"": "a &lt; b &amp; &#34;c&#34;",
+	}
+	for in, want := range tests {
+		if got := highlightSnippet(in); got != want {
+			t.Errorf("highlightSnippet(%q) = %q, want %q", in, got, want)
+		}
+	}
+}